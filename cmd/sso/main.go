@@ -18,8 +18,30 @@ func main() {
 	defer container.Close()
 
 	SSOService := container.GetSSOService()
+	JWTService := container.GetJWTService()
+	OIDCService := container.GetOIDCService()
+	ClientService := container.GetClientService()
+	IntrospectionService := container.GetIntrospectionService()
+	SessionService := container.GetSessionService()
+	FraudService := container.GetFraudService()
+	SMSService := container.GetSMSService()
+	MindboxOutboxService := container.GetMindboxOutboxService()
+	ConsentService := container.GetConsentService()
 	logger := container.GetLogger()
 
-	handler := apiHandler.NewVerificationHandler(SSOService, logger)
-	api.StartServer(handler, cfg, logger)
+	handlers := &api.Handlers{
+		Verification:  apiHandler.NewVerificationHandler(SSOService, logger),
+		JWKS:          apiHandler.NewJWKSHandler(JWTService),
+		Client:        apiHandler.NewClientHandler(ClientService, logger),
+		Introspection: apiHandler.NewIntrospectionHandler(IntrospectionService, logger),
+		Session:       apiHandler.NewSessionHandler(SessionService, JWTService, logger),
+		Fraud:         apiHandler.NewFraudHandler(FraudService, logger),
+		SMS:           apiHandler.NewSMSHandler(SMSService, logger),
+		Mindbox:       apiHandler.NewMindboxHandler(MindboxOutboxService, logger),
+		Subscription:  apiHandler.NewSubscriptionHandler(ConsentService, JWTService, logger),
+	}
+	if OIDCService != nil {
+		handlers.OIDC = apiHandler.NewOIDCHandler(OIDCService, cfg.OIDC.LoginURL, logger)
+	}
+	api.StartServer(handlers, cfg, logger)
 }
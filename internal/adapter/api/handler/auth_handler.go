@@ -6,6 +6,8 @@ import (
 	dto "sso/internal/adapter/api/handler/dto"
 	"sso/internal/logger"
 	"sso/internal/service"
+	"sso/pkg/apperror"
+	"sso/pkg/clientip"
 	response "sso/pkg/response"
 	"strings"
 )
@@ -24,7 +26,7 @@ func NewVerificationHandler(s service.SSOService, logger *logger.Logger) *Verifi
 func (h *VerificationHandler) Verification(w http.ResponseWriter, r *http.Request) {
 	var req dto.VerificationRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		response.Return(w, http.StatusOK, false, "Invalid request format", nil)
+		response.AppError(w, apperror.ErrInvalidRequest)
 		return
 	}
 
@@ -35,88 +37,138 @@ func (h *VerificationHandler) Verification(w http.ResponseWriter, r *http.Reques
 		}
 	}
 
-	err := h.SSOService.Verification(req.Phone, req.Signature, req.Platform)
+	result, err := h.SSOService.Verification(r.Context(), service.VerificationRequest{
+		Phone:          req.Phone,
+		Channel:        req.Channel,
+		Signature:      req.Signature,
+		Platform:       req.Platform,
+		Brand:          req.Brand,
+		AcceptLanguage: r.Header.Get("Accept-Language"),
+		DeviceUUID:     r.Header.Get("X-DeviceUUID"),
+		Agent:          r.UserAgent(),
+		IP:             clientIP(r),
+		CaptchaToken:   req.CaptchaToken,
+	})
 	if err != nil {
-		if strings.Contains(err.Error(), "invalid phone number") {
-			response.Return(w, http.StatusOK, false, err.Error(), nil)
-			return
-		}
-		if strings.Contains(err.Error(), "failed to save verification code") {
-			response.Return(w, http.StatusInternalServerError, false, "Internal server error", nil)
-			return
-		}
-		response.Return(w, http.StatusOK, false, "Failed to send verification code", nil)
+		h.logError(r, "Error from SSOService.Verification", err)
+		response.AppError(w, err)
 		return
 	}
 
-	response.Return(w, http.StatusOK, true, "Verification code sent successfully", nil)
+	response.Return(w, http.StatusOK, true, "Verification code sent successfully", dto.VerificationResult{
+		VerificationID: result.ID,
+		Channel:        result.Channel,
+		ResendAfter:    int64(result.ResendAfter.Seconds()),
+	})
 }
 
 func (h *VerificationHandler) Login(w http.ResponseWriter, r *http.Request) {
 	var req dto.LoginRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.Logger.Error("Error decoding request body", "error", err)
-		response.Return(w, http.StatusOK, false, "Invalid request format", nil)
+		h.Logger.ErrorContext(r.Context(), "Error decoding request body", "error", err)
+		response.AppError(w, apperror.ErrInvalidRequest)
 		return
 	}
 
+	// The platform is the one the code was requested from.
 	deviceUUID := r.Header.Get("X-DeviceUUID")
-	platform := r.Header.Get("platform")
-	if platform == "" {
-		platform = "web"
+	ip := clientIP(r)
+	agent := r.UserAgent()
+	tokens, err := h.SSOService.Login(r.Context(), req.VerificationID, req.Phone, req.Code, deviceUUID, agent, ip, req.WebsiteID, consentFromRequest(req.Consent))
+	if err != nil {
+		h.logError(r, "Error from SSOService.Login", err)
+		response.AppError(w, err)
+		return
 	}
 
-	ip := r.RemoteAddr
-	if forwardedFor := r.Header.Get("X-Forwarded-For"); forwardedFor != "" {
-		ip = strings.Split(forwardedFor, ",")[0]
+	response.Return(w, http.StatusOK, true, "Login successful", dto.TokenResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+	})
+}
+
+func (h *VerificationHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req dto.RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.Logger.ErrorContext(r.Context(), "Error decoding request body", "error", err)
+		response.AppError(w, apperror.ErrInvalidRequest)
+		return
 	}
 
-	agent := r.UserAgent()
-	token, err := h.SSOService.Login(req.Phone, req.Code, platform, deviceUUID, agent, ip, req.WebsiteID)
+	tokens, err := h.SSOService.Refresh(r.Context(), req.RefreshToken, "", r.UserAgent(), clientIP(r))
 	if err != nil {
-		h.Logger.Error("Error from SSOService.Login", "error", err)
-		if strings.Contains(err.Error(), "invalid phone number") ||
-			strings.Contains(err.Error(), "invalid verification code") ||
-			strings.Contains(err.Error(), "verification code expired") {
-			response.Return(w, http.StatusOK, false, err.Error(), nil)
-			return
-		}
-		if strings.Contains(err.Error(), "failed to save token") {
-			response.Return(w, http.StatusInternalServerError, false, "Internal server error", nil)
-			return
-		}
-		response.Return(w, http.StatusOK, false, "Login failed", nil)
+		h.logError(r, "Error from SSOService.Refresh", err)
+		response.AppError(w, err)
 		return
 	}
 
-	response.Return(w, http.StatusOK, true, "Login successful", map[string]string{
-		"token": token,
+	response.Return(w, http.StatusOK, true, "Token refreshed", dto.TokenResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
 	})
 }
 
 func (h *VerificationHandler) Logout(w http.ResponseWriter, r *http.Request) {
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		h.Logger.Error("Logout failed: Authorization header is missing")
-		response.Return(w, http.StatusUnauthorized, false, "Authorization header is required", nil)
+	if r.Header.Get("Authorization") == "" {
+		h.Logger.ErrorContext(r.Context(), "Logout failed: Authorization header is missing")
+		response.AppError(w, apperror.ErrUnauthorized)
 		return
 	}
 
-	parts := strings.Split(authHeader, " ")
-	if len(parts) != 2 || parts[0] != "Bearer" {
-		h.Logger.Error("Logout failed: Invalid authorization header format")
-		response.Return(w, http.StatusUnauthorized, false, "Invalid authorization header format", nil)
+	token, ok := bearerToken(r)
+	if !ok {
+		h.Logger.ErrorContext(r.Context(), "Logout failed: Invalid authorization header format")
+		response.AppError(w, apperror.ErrUnauthorized.WithMessage("Invalid authorization header format"))
 		return
 	}
 
-	token := parts[1]
-
-	err := h.SSOService.Logout(token)
+	err := h.SSOService.Logout(r.Context(), token)
 	if err != nil {
-		h.Logger.Error("Logout error", "error", err)
-		response.Return(w, http.StatusUnauthorized, false, err.Error(), nil)
+		h.logError(r, "Logout error", err)
+		response.AppError(w, err)
 		return
 	}
 
 	response.Return(w, http.StatusOK, true, "Successfully logged out", nil)
 }
+
+// logError logs caller mistakes as warnings and only server faults as errors.
+func (h *VerificationHandler) logError(r *http.Request, msg string, err error) {
+	if apperror.IsServerError(err) {
+		h.Logger.ErrorContext(r.Context(), msg, "error", err)
+		return
+	}
+	h.Logger.WarnContext(r.Context(), msg, "error", err)
+}
+
+// consentFromRequest returns the consent stated in a request; channels it
+// leaves out stay unstated.
+func consentFromRequest(c *dto.Consent) service.Consent {
+	if c == nil {
+		return service.Consent{}
+	}
+	return service.Consent{
+		Email:   c.Email,
+		SMS:     c.SMS,
+		Webpush: c.Webpush,
+	}
+}
+
+// clientIP returns the client address the ClientIP middleware resolved from
+// the trusted proxies' X-Forwarded-For hops, or else the peer address.
+func clientIP(r *http.Request) string {
+	if ip := clientip.FromContext(r.Context()); ip != "" {
+		return ip
+	}
+	return clientip.Resolve(r, nil)
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	parts := strings.Split(r.Header.Get("Authorization"), " ")
+	if len(parts) != 2 || parts[0] != "Bearer" || parts[1] == "" {
+		return "", false
+	}
+	return parts[1], true
+}
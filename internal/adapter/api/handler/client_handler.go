@@ -0,0 +1,137 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	dto "sso/internal/adapter/api/handler/dto"
+	"sso/internal/logger"
+	"sso/internal/models"
+	"sso/internal/service"
+	"sso/pkg/apperror"
+	response "sso/pkg/response"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ClientHandler serves the admin API for the OAuth client registry.
+type ClientHandler struct {
+	ClientService service.ClientService
+	Logger        *logger.Logger
+}
+
+func NewClientHandler(s service.ClientService, logger *logger.Logger) *ClientHandler {
+	return &ClientHandler{
+		ClientService: s,
+		Logger:        logger,
+	}
+}
+
+func (h *ClientHandler) List(w http.ResponseWriter, r *http.Request) {
+	clients, err := h.ClientService.List()
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	result := make([]dto.ClientResponse, 0, len(clients))
+	for i := range clients {
+		result = append(result, clientResponse(&clients[i], ""))
+	}
+	response.Return(w, http.StatusOK, true, "Clients", result)
+}
+
+func (h *ClientHandler) Get(w http.ResponseWriter, r *http.Request) {
+	client, err := h.ClientService.Get(chi.URLParam(r, "clientID"))
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	response.Return(w, http.StatusOK, true, "Client", clientResponse(client, ""))
+}
+
+// Create registers a client. The client secret is only ever returned here and
+// by RotateSecret.
+func (h *ClientHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req dto.ClientRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.AppError(w, apperror.ErrInvalidRequest)
+		return
+	}
+
+	client, secret, err := h.ClientService.Register(clientInput(req))
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	response.Return(w, http.StatusCreated, true, "Client registered", clientResponse(client, secret))
+}
+
+func (h *ClientHandler) Update(w http.ResponseWriter, r *http.Request) {
+	var req dto.ClientRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.AppError(w, apperror.ErrInvalidRequest)
+		return
+	}
+
+	client, err := h.ClientService.Update(chi.URLParam(r, "clientID"), clientInput(req))
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	response.Return(w, http.StatusOK, true, "Client updated", clientResponse(client, ""))
+}
+
+func (h *ClientHandler) Deactivate(w http.ResponseWriter, r *http.Request) {
+	if err := h.ClientService.Deactivate(chi.URLParam(r, "clientID")); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	response.Return(w, http.StatusOK, true, "Client deactivated", nil)
+}
+
+func (h *ClientHandler) RotateSecret(w http.ResponseWriter, r *http.Request) {
+	clientID := chi.URLParam(r, "clientID")
+	secret, err := h.ClientService.RotateSecret(clientID)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	response.Return(w, http.StatusOK, true, "Client secret rotated", dto.ClientSecretResponse{
+		ClientID:     clientID,
+		ClientSecret: secret,
+	})
+}
+
+func (h *ClientHandler) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	if apperror.IsServerError(err) {
+		h.Logger.ErrorContext(r.Context(), "Error from ClientService", "error", err)
+	}
+	response.AppError(w, err)
+}
+
+func clientInput(req dto.ClientRequest) service.ClientInput {
+	return service.ClientInput{
+		ClientID:     req.ClientID,
+		Name:         req.Name,
+		RedirectURIs: req.RedirectURIs,
+		GrantTypes:   req.GrantTypes,
+		Scopes:       req.Scopes,
+		Confidential: req.Confidential,
+	}
+}
+
+func clientResponse(client *models.Client, secret string) dto.ClientResponse {
+	return dto.ClientResponse{
+		ClientID:     client.ClientID,
+		ClientSecret: secret,
+		Name:         client.Name,
+		RedirectURIs: client.RedirectURIList(),
+		GrantTypes:   client.GrantTypeList(),
+		Scopes:       client.ScopeList(),
+		Confidential: client.IsConfidential() || secret != "",
+		IsActive:     client.IsActive,
+		CreatedAt:    client.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:    client.UpdatedAt.Format(time.RFC3339),
+	}
+}
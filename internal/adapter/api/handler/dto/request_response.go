@@ -1,9 +1,19 @@
 package dto
 
 type LoginRequest struct {
-	Phone     string `json:"phone"`
-	Code      string `json:"code"`
-	WebsiteID string `json:"websiteID,omitempty"`
+	VerificationID string   `json:"verification_id"`
+	Phone          string   `json:"phone"`
+	Code           string   `json:"code"`
+	WebsiteID      string   `json:"websiteID,omitempty"`
+	Consent        *Consent `json:"consent,omitempty"`
+}
+
+// Consent is the user's consent to be contacted over each point of contact.
+// An omitted field keeps the stored choice.
+type Consent struct {
+	Email   *bool `json:"email,omitempty"`
+	SMS     *bool `json:"sms,omitempty"`
+	Webpush *bool `json:"webpush,omitempty"`
 }
 
 type LoginResponse struct {
@@ -11,18 +21,145 @@ type LoginResponse struct {
 	Token   string `json:"token"`
 }
 
+type TokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
 type LogoutResponse struct {
 	Message string `json:"message"`
 }
 
 type VerificationRequest struct {
-	Phone     string `json:"phone"`
-	Signature string `json:"signature"`
-	Platform  string `json:"platform,omitempty"`
-	WebsiteID string `json:"websiteID,omitempty"`
+	Phone        string `json:"phone"`
+	Channel      string `json:"channel,omitempty"`
+	Signature    string `json:"signature"`
+	Platform     string `json:"platform,omitempty"`
+	Brand        string `json:"brand,omitempty"`
+	WebsiteID    string `json:"websiteID,omitempty"`
+	CaptchaToken string `json:"captcha_token,omitempty"`
 }
 
 type VerificationResponse struct {
 	Success bool   `json:"success"`
 	Message string `json:"message"`
 }
+
+type VerificationResult struct {
+	VerificationID string `json:"verification_id"`
+	Channel        string `json:"channel"`
+	ResendAfter    int64  `json:"resend_after"`
+}
+
+type FraudRuleRequest struct {
+	Action string `json:"action"`
+}
+
+type FraudRuleResponse struct {
+	Prefix     string  `json:"prefix"`
+	Action     string  `json:"action"`
+	Manual     bool    `json:"manual"`
+	Sent       int64   `json:"sent"`
+	Logins     int64   `json:"logins"`
+	Conversion float64 `json:"conversion"`
+	CreatedAt  string  `json:"created_at"`
+	ExpiresAt  string  `json:"expires_at,omitempty"`
+}
+
+type SMSMessageResponse struct {
+	ID                int64  `json:"id"`
+	Phone             string `json:"phone"`
+	Provider          string `json:"provider"`
+	ProviderMessageID string `json:"provider_message_id,omitempty"`
+	Status            string `json:"status"`
+	ProviderStatus    string `json:"provider_status,omitempty"`
+	Parts             int    `json:"parts"`
+	Cost              string `json:"cost,omitempty"`
+	Error             string `json:"error,omitempty"`
+	CreatedAt         string `json:"created_at"`
+	SentAt            string `json:"sent_at,omitempty"`
+	DeliveredAt       string `json:"delivered_at,omitempty"`
+	CheckedAt         string `json:"checked_at,omitempty"`
+}
+
+type MindboxOutboxEventResponse struct {
+	ID            int64  `json:"id"`
+	UserID        int64  `json:"user_id"`
+	Operation     string `json:"operation"`
+	Payload       string `json:"payload"`
+	Status        string `json:"status"`
+	Attempts      int    `json:"attempts"`
+	LastError     string `json:"last_error,omitempty"`
+	NextAttemptAt string `json:"next_attempt_at"`
+	SentAt        string `json:"sent_at,omitempty"`
+	CreatedAt     string `json:"created_at"`
+}
+
+type AuthorizeResponse struct {
+	AuthRequestID string `json:"auth_request_id"`
+}
+
+type CompleteAuthorizationRequest struct {
+	AuthRequestID  string   `json:"auth_request_id"`
+	VerificationID string   `json:"verification_id"`
+	Phone          string   `json:"phone"`
+	Code           string   `json:"code"`
+	Consent        *Consent `json:"consent,omitempty"`
+}
+
+type CompleteAuthorizationResponse struct {
+	RedirectTo string `json:"redirect_to"`
+}
+
+type OAuthErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+type ClientRequest struct {
+	ClientID     string   `json:"client_id,omitempty"`
+	Name         string   `json:"name"`
+	RedirectURIs []string `json:"redirect_uris"`
+	GrantTypes   []string `json:"grant_types,omitempty"`
+	Scopes       []string `json:"scopes,omitempty"`
+	Confidential bool     `json:"confidential"`
+}
+
+type ClientResponse struct {
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret,omitempty"`
+	Name         string   `json:"name"`
+	RedirectURIs []string `json:"redirect_uris"`
+	GrantTypes   []string `json:"grant_types"`
+	Scopes       []string `json:"scopes"`
+	Confidential bool     `json:"confidential"`
+	IsActive     bool     `json:"is_active"`
+	CreatedAt    string   `json:"created_at"`
+	UpdatedAt    string   `json:"updated_at"`
+}
+
+type ClientSecretResponse struct {
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+}
+
+type SessionResponse struct {
+	ID         int64  `json:"id"`
+	ClientID   string `json:"client_id,omitempty"`
+	Platform   string `json:"platform"`
+	DeviceUUID string `json:"device_uuid,omitempty"`
+	Agent      string `json:"agent"`
+	IP         string `json:"ip"`
+	LoginAt    string `json:"login_at"`
+	LastSeenAt string `json:"last_seen_at"`
+	Current    bool   `json:"current"`
+}
+
+type RevokeSessionsResponse struct {
+	Revoked int `json:"revoked"`
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	dto "sso/internal/adapter/api/handler/dto"
+	"sso/internal/logger"
+	"sso/internal/service"
+	"sso/pkg/apperror"
+	response "sso/pkg/response"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// FraudHandler serves the admin API for number prefixes that are blocked or
+// challenged because of SMS pumping.
+type FraudHandler struct {
+	FraudService service.FraudService
+	Logger       *logger.Logger
+}
+
+func NewFraudHandler(s service.FraudService, logger *logger.Logger) *FraudHandler {
+	return &FraudHandler{
+		FraudService: s,
+		Logger:       logger,
+	}
+}
+
+func (h *FraudHandler) List(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.FraudService.Rules(r.Context())
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	result := make([]dto.FraudRuleResponse, 0, len(rules))
+	for i := range rules {
+		result = append(result, fraudRuleResponse(&rules[i]))
+	}
+	response.Return(w, http.StatusOK, true, "Fraud rules", result)
+}
+
+// Set blocks or challenges a prefix until it is deleted.
+func (h *FraudHandler) Set(w http.ResponseWriter, r *http.Request) {
+	var req dto.FraudRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.AppError(w, apperror.ErrInvalidRequest)
+		return
+	}
+
+	rule, err := h.FraudService.SetRule(r.Context(), chi.URLParam(r, "prefix"), service.FraudAction(req.Action))
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	response.Return(w, http.StatusOK, true, "Fraud rule saved", fraudRuleResponse(rule))
+}
+
+func (h *FraudHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if err := h.FraudService.DeleteRule(r.Context(), chi.URLParam(r, "prefix")); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	response.Return(w, http.StatusOK, true, "Fraud rule deleted", nil)
+}
+
+func (h *FraudHandler) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	if apperror.IsServerError(err) {
+		h.Logger.ErrorContext(r.Context(), "Error from FraudService", "error", err)
+	}
+	response.AppError(w, err)
+}
+
+func fraudRuleResponse(rule *service.FraudRule) dto.FraudRuleResponse {
+	result := dto.FraudRuleResponse{
+		Prefix:     rule.Prefix,
+		Action:     string(rule.Action),
+		Manual:     rule.Manual,
+		Sent:       rule.Sent,
+		Logins:     rule.Logins,
+		Conversion: rule.Conversion,
+		CreatedAt:  rule.CreatedAt.Format(time.RFC3339),
+	}
+	if rule.ExpiresAt != nil {
+		result.ExpiresAt = rule.ExpiresAt.Format(time.RFC3339)
+	}
+	return result
+}
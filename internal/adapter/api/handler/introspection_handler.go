@@ -0,0 +1,51 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"sso/internal/logger"
+	"sso/internal/service"
+	response "sso/pkg/response"
+)
+
+type IntrospectionHandler struct {
+	IntrospectionService service.IntrospectionService
+	Logger               *logger.Logger
+}
+
+func NewIntrospectionHandler(s service.IntrospectionService, logger *logger.Logger) *IntrospectionHandler {
+	return &IntrospectionHandler{
+		IntrospectionService: s,
+		Logger:               logger,
+	}
+}
+
+// Introspect implements RFC 7662 for internal services. The caller
+// authenticates with its client credentials, like at the token endpoint.
+func (h *IntrospectionHandler) Introspect(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, &service.OAuthError{Code: "invalid_request", Description: "malformed form body", Status: http.StatusBadRequest})
+		return
+	}
+
+	clientID, clientSecret, ok := clientCredentials(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Basic realm="introspect"`)
+		writeOAuthError(w, &service.OAuthError{Code: "invalid_client", Description: "malformed client credentials", Status: http.StatusUnauthorized})
+		return
+	}
+
+	result, err := h.IntrospectionService.Introspect(clientID, clientSecret, r.PostForm.Get("token"), r.PostForm.Get("token_type_hint"))
+	if err != nil {
+		var oauthErr *service.OAuthError
+		if errors.As(err, &oauthErr) && oauthErr.Code == "invalid_client" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="introspect"`)
+		}
+		writeOAuthError(w, err)
+		return
+	}
+
+	response.Result(w, http.StatusOK, result)
+}
@@ -0,0 +1,23 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sso/internal/service"
+	response "sso/pkg/response"
+)
+
+type JWKSHandler struct {
+	JWTService service.JWTService
+}
+
+func NewJWKSHandler(s service.JWTService) *JWKSHandler {
+	return &JWKSHandler{
+		JWTService: s,
+	}
+}
+
+func (h *JWKSHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(service.JWKSMaxAge.Seconds())))
+	response.Result(w, http.StatusOK, h.JWTService.JWKS())
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"net/http"
+	dto "sso/internal/adapter/api/handler/dto"
+	"sso/internal/logger"
+	"sso/internal/models"
+	"sso/internal/service"
+	"sso/pkg/apperror"
+	response "sso/pkg/response"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultMindboxOutboxLimit = 50
+	maxMindboxOutboxLimit     = 500
+)
+
+// MindboxHandler lets support inspect Mindbox operations that could not be
+// sent and queue them again once the cause is fixed.
+type MindboxHandler struct {
+	OutboxService service.MindboxOutboxService
+	Logger        *logger.Logger
+}
+
+func NewMindboxHandler(s service.MindboxOutboxService, logger *logger.Logger) *MindboxHandler {
+	return &MindboxHandler{
+		OutboxService: s,
+		Logger:        logger,
+	}
+}
+
+// Outbox lists events by status, dead ones unless ?status= says otherwise,
+// optionally for one ?user_id=.
+func (h *MindboxHandler) Outbox(w http.ResponseWriter, r *http.Request) {
+	limit := defaultMindboxOutboxLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			response.AppError(w, apperror.ErrInvalidRequest.WithMessage("limit must be a positive integer"))
+			return
+		}
+		limit = min(parsed, maxMindboxOutboxLimit)
+	}
+
+	var userID int64
+	if value := r.URL.Query().Get("user_id"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed <= 0 {
+			response.AppError(w, apperror.ErrInvalidRequest.WithMessage("user_id must be a positive integer"))
+			return
+		}
+		userID = parsed
+	}
+
+	events, err := h.OutboxService.List(r.URL.Query().Get("status"), userID, limit)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	result := make([]dto.MindboxOutboxEventResponse, 0, len(events))
+	for i := range events {
+		result = append(result, mindboxOutboxEventResponse(&events[i]))
+	}
+	response.Return(w, http.StatusOK, true, "Mindbox outbox events", result)
+}
+
+func (h *MindboxHandler) Replay(w http.ResponseWriter, r *http.Request) {
+	eventID, err := strconv.ParseInt(chi.URLParam(r, "eventID"), 10, 64)
+	if err != nil {
+		response.AppError(w, apperror.ErrInvalidRequest.WithMessage("Invalid event id"))
+		return
+	}
+
+	if err := h.OutboxService.Replay(eventID); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+	response.Return(w, http.StatusOK, true, "Mindbox event queued", nil)
+}
+
+func (h *MindboxHandler) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	if apperror.IsServerError(err) {
+		h.Logger.ErrorContext(r.Context(), "Error from MindboxOutboxService", "error", err)
+	}
+	response.AppError(w, err)
+}
+
+func mindboxOutboxEventResponse(event *models.MindboxOutboxEvent) dto.MindboxOutboxEventResponse {
+	return dto.MindboxOutboxEventResponse{
+		ID:            event.ID,
+		UserID:        event.UserID,
+		Operation:     event.Operation,
+		Payload:       event.Payload,
+		Status:        event.Status,
+		Attempts:      event.Attempts,
+		LastError:     event.LastError,
+		NextAttemptAt: event.NextAttemptAt.Format(time.RFC3339),
+		SentAt:        formatNullTime(event.SentAt),
+		CreatedAt:     event.CreatedAt.Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,221 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	dto "sso/internal/adapter/api/handler/dto"
+	"sso/internal/logger"
+	"sso/internal/service"
+	"sso/pkg/apperror"
+	response "sso/pkg/response"
+)
+
+type OIDCHandler struct {
+	OIDCService service.OIDCService
+	LoginURL    string
+	Logger      *logger.Logger
+}
+
+func NewOIDCHandler(s service.OIDCService, loginURL string, logger *logger.Logger) *OIDCHandler {
+	return &OIDCHandler{
+		OIDCService: s,
+		LoginURL:    loginURL,
+		Logger:      logger,
+	}
+}
+
+func (h *OIDCHandler) Discovery(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	response.Result(w, http.StatusOK, h.OIDCService.Discovery())
+}
+
+// Authorize starts the authorization code flow and hands the user over to the
+// shared SMS login page, which resumes the request with auth_request_id.
+func (h *OIDCHandler) Authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	req := &service.AuthorizationRequest{
+		ClientID:            query.Get("client_id"),
+		RedirectURI:         query.Get("redirect_uri"),
+		ResponseType:        query.Get("response_type"),
+		Scope:               query.Get("scope"),
+		State:               query.Get("state"),
+		Nonce:               query.Get("nonce"),
+		CodeChallenge:       query.Get("code_challenge"),
+		CodeChallengeMethod: query.Get("code_challenge_method"),
+	}
+
+	if err := h.OIDCService.ValidateRedirect(req.ClientID, req.RedirectURI); err != nil {
+		writeOAuthError(w, err)
+		return
+	}
+
+	if err := h.OIDCService.Authorize(req); err != nil {
+		var oauthErr *service.OAuthError
+		if !errors.As(err, &oauthErr) {
+			oauthErr = &service.OAuthError{Code: "server_error", Description: "authorization failed"}
+		}
+		params := url.Values{}
+		params.Set("error", oauthErr.Code)
+		params.Set("error_description", oauthErr.Description)
+		if req.State != "" {
+			params.Set("state", req.State)
+		}
+		http.Redirect(w, r, service.AppendQuery(req.RedirectURI, params), http.StatusFound)
+		return
+	}
+
+	if h.LoginURL == "" {
+		response.Return(w, http.StatusOK, true, "Authorization request created", dto.AuthorizeResponse{
+			AuthRequestID: req.ID,
+		})
+		return
+	}
+
+	params := url.Values{}
+	params.Set("auth_request_id", req.ID)
+	http.Redirect(w, r, service.AppendQuery(h.LoginURL, params), http.StatusFound)
+}
+
+// CompleteAuthorization is called by the login page once the user has entered
+// the SMS code. It returns the URL the browser must be sent to.
+func (h *OIDCHandler) CompleteAuthorization(w http.ResponseWriter, r *http.Request) {
+	var req dto.CompleteAuthorizationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.Logger.WarnContext(r.Context(), "Error decoding request body", "error", err)
+		response.AppError(w, apperror.ErrInvalidRequest)
+		return
+	}
+
+	redirectTo, err := h.OIDCService.CompleteAuthorization(
+		r.Context(),
+		req.AuthRequestID,
+		req.VerificationID,
+		req.Phone,
+		req.Code,
+		r.Header.Get("X-DeviceUUID"),
+		r.UserAgent(),
+		clientIP(r),
+		consentFromRequest(req.Consent),
+	)
+	if err != nil {
+		h.logError(r, "Error from OIDCService.CompleteAuthorization", err)
+		var oauthErr *service.OAuthError
+		if errors.As(err, &oauthErr) {
+			response.Return(w, oauthErr.Status, false, oauthErr.Description, nil)
+			return
+		}
+		response.AppError(w, err)
+		return
+	}
+
+	response.Return(w, http.StatusOK, true, "Authorization granted", dto.CompleteAuthorizationResponse{
+		RedirectTo: redirectTo,
+	})
+}
+
+func (h *OIDCHandler) Token(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, &service.OAuthError{Code: "invalid_request", Description: "malformed form body", Status: http.StatusBadRequest})
+		return
+	}
+
+	clientID, clientSecret, ok := clientCredentials(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Basic realm="token"`)
+		writeOAuthError(w, &service.OAuthError{Code: "invalid_client", Description: "malformed client credentials", Status: http.StatusUnauthorized})
+		return
+	}
+
+	tokens, err := h.OIDCService.Exchange(r.Context(), &service.TokenRequest{
+		GrantType:    r.PostForm.Get("grant_type"),
+		Code:         r.PostForm.Get("code"),
+		RedirectURI:  r.PostForm.Get("redirect_uri"),
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		CodeVerifier: r.PostForm.Get("code_verifier"),
+		RefreshToken: r.PostForm.Get("refresh_token"),
+		Agent:        r.UserAgent(),
+		IP:           clientIP(r),
+	})
+	if err != nil {
+		h.logError(r, "Error from OIDCService.Exchange", err)
+		var oauthErr *service.OAuthError
+		if errors.As(err, &oauthErr) && oauthErr.Code == "invalid_client" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="token"`)
+		}
+		writeOAuthError(w, err)
+		return
+	}
+
+	response.Result(w, http.StatusOK, tokens)
+}
+
+func (h *OIDCHandler) UserInfo(w http.ResponseWriter, r *http.Request) {
+	token, ok := bearerToken(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="userinfo"`)
+		writeOAuthError(w, &service.OAuthError{Code: "invalid_token", Description: "bearer token is required", Status: http.StatusUnauthorized})
+		return
+	}
+
+	userInfo, err := h.OIDCService.UserInfo(token)
+	if err != nil {
+		var oauthErr *service.OAuthError
+		if errors.As(err, &oauthErr) && oauthErr.Code == "invalid_token" {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			oauthErr.Status = http.StatusUnauthorized
+		}
+		writeOAuthError(w, err)
+		return
+	}
+
+	response.Result(w, http.StatusOK, userInfo)
+}
+
+// logError logs caller mistakes as warnings and only server faults as errors.
+func (h *OIDCHandler) logError(r *http.Request, msg string, err error) {
+	var oauthErr *service.OAuthError
+	serverError := apperror.IsServerError(err)
+	if errors.As(err, &oauthErr) {
+		serverError = oauthErr.Code == "server_error"
+	}
+	if serverError {
+		h.Logger.ErrorContext(r.Context(), msg, "error", err)
+		return
+	}
+	h.Logger.WarnContext(r.Context(), msg, "error", err)
+}
+
+func writeOAuthError(w http.ResponseWriter, err error) {
+	var oauthErr *service.OAuthError
+	if !errors.As(err, &oauthErr) {
+		oauthErr = &service.OAuthError{Code: "server_error", Description: "internal server error", Status: http.StatusInternalServerError}
+	}
+	response.Result(w, oauthErr.Status, dto.OAuthErrorResponse{
+		Error:            oauthErr.Code,
+		ErrorDescription: oauthErr.Description,
+	})
+}
+
+// clientCredentials reads client_secret_basic or client_secret_post
+// credentials. Basic credentials are form-encoded as RFC 6749 section 2.3.1
+// requires; ok is false when they cannot be decoded.
+func clientCredentials(r *http.Request) (clientID, clientSecret string, ok bool) {
+	if username, password, basic := r.BasicAuth(); basic {
+		clientID, err := url.QueryUnescape(username)
+		if err != nil {
+			return "", "", false
+		}
+		clientSecret, err := url.QueryUnescape(password)
+		if err != nil {
+			return "", "", false
+		}
+		return clientID, clientSecret, true
+	}
+	return r.PostForm.Get("client_id"), r.PostForm.Get("client_secret"), true
+}
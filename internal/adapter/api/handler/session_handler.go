@@ -0,0 +1,136 @@
+package api
+
+import (
+	"net/http"
+	dto "sso/internal/adapter/api/handler/dto"
+	"sso/internal/logger"
+	"sso/internal/service"
+	"sso/pkg/apperror"
+	response "sso/pkg/response"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// SessionHandler lets users see and end their own sessions. Only first-party
+// access tokens are accepted; tokens issued to OAuth clients are not.
+type SessionHandler struct {
+	SessionService service.SessionService
+	JWTService     service.JWTService
+	Logger         *logger.Logger
+}
+
+func NewSessionHandler(s service.SessionService, jwtService service.JWTService, logger *logger.Logger) *SessionHandler {
+	return &SessionHandler{
+		SessionService: s,
+		JWTService:     jwtService,
+		Logger:         logger,
+	}
+}
+
+func (h *SessionHandler) List(w http.ResponseWriter, r *http.Request) {
+	claims, token, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.SessionService.List(claims.UserID, token)
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "Error from SessionService.List", "user_id", claims.UserID, "error", err)
+		response.AppError(w, err)
+		return
+	}
+
+	result := make([]dto.SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		result = append(result, dto.SessionResponse{
+			ID:         session.ID,
+			ClientID:   session.ClientID,
+			Platform:   session.Platform,
+			DeviceUUID: session.DeviceUUID,
+			Agent:      session.Agent,
+			IP:         session.IP,
+			LoginAt:    session.LoginAt.Format(time.RFC3339),
+			LastSeenAt: session.LastSeenAt.Format(time.RFC3339),
+			Current:    session.Current,
+		})
+	}
+	response.Return(w, http.StatusOK, true, "Sessions", result)
+}
+
+func (h *SessionHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	claims, _, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	sessionID, err := strconv.ParseInt(chi.URLParam(r, "sessionID"), 10, 64)
+	if err != nil {
+		response.AppError(w, apperror.ErrInvalidRequest.WithMessage("Invalid session id"))
+		return
+	}
+
+	err = h.SessionService.Revoke(claims.UserID, sessionID)
+	if err != nil {
+		if apperror.IsServerError(err) {
+			h.Logger.ErrorContext(r.Context(), "Error from SessionService.Revoke", "user_id", claims.UserID, "session_id", sessionID, "error", err)
+		}
+		response.AppError(w, err)
+		return
+	}
+
+	response.Return(w, http.StatusOK, true, "Session revoked", nil)
+}
+
+// RevokeOthers logs the user out everywhere except the calling session.
+func (h *SessionHandler) RevokeOthers(w http.ResponseWriter, r *http.Request) {
+	claims, token, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	revoked, err := h.SessionService.RevokeOthers(claims.UserID, token)
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "Error from SessionService.RevokeOthers", "user_id", claims.UserID, "error", err)
+		response.AppError(w, err)
+		return
+	}
+
+	response.Return(w, http.StatusOK, true, "Other sessions revoked", dto.RevokeSessionsResponse{
+		Revoked: revoked,
+	})
+}
+
+func (h *SessionHandler) authenticate(w http.ResponseWriter, r *http.Request) (*service.Claims, string, bool) {
+	return authenticateFirstParty(w, r, h.JWTService, "Sessions")
+}
+
+// authenticateFirstParty returns the claims and the bearer token of a request
+// made with a first-party access token. Otherwise it writes the error and
+// reports false; what names the resource for the error message.
+func authenticateFirstParty(w http.ResponseWriter, r *http.Request, jwtService service.JWTService, what string) (*service.Claims, string, bool) {
+	token, ok := bearerToken(r)
+	if !ok {
+		response.AppError(w, apperror.ErrUnauthorized)
+		return nil, "", false
+	}
+
+	parsed, err := jwtService.ValidateToken(token)
+	if err != nil {
+		response.AppError(w, apperror.ErrInvalidToken)
+		return nil, "", false
+	}
+
+	claims, ok := parsed.Claims.(*service.Claims)
+	if !ok {
+		response.AppError(w, apperror.ErrInvalidToken)
+		return nil, "", false
+	}
+	if claims.ClientID != "" {
+		response.AppError(w, apperror.ErrForbidden.WithMessage(what+" can only be managed with a first-party token"))
+		return nil, "", false
+	}
+
+	return claims, token, true
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	dto "sso/internal/adapter/api/handler/dto"
+	"sso/internal/logger"
+	"sso/internal/models"
+	"sso/internal/service"
+	"sso/pkg/apperror"
+	response "sso/pkg/response"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultSMSHistoryLimit = 50
+	maxSMSHistoryLimit     = 500
+)
+
+// SMSHandler lets support look up what was sent to a phone and whether it
+// arrived.
+type SMSHandler struct {
+	SMSService service.SMSService
+	Logger     *logger.Logger
+}
+
+func NewSMSHandler(s service.SMSService, logger *logger.Logger) *SMSHandler {
+	return &SMSHandler{
+		SMSService: s,
+		Logger:     logger,
+	}
+}
+
+func (h *SMSHandler) History(w http.ResponseWriter, r *http.Request) {
+	limit := defaultSMSHistoryLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			response.AppError(w, apperror.ErrInvalidRequest.WithMessage("limit must be a positive integer"))
+			return
+		}
+		limit = min(parsed, maxSMSHistoryLimit)
+	}
+
+	messages, err := h.SMSService.History(r.URL.Query().Get("phone"), limit)
+	if err != nil {
+		if apperror.IsServerError(err) {
+			h.Logger.ErrorContext(r.Context(), "Error from SMSService.History", "error", err)
+		}
+		response.AppError(w, err)
+		return
+	}
+
+	result := make([]dto.SMSMessageResponse, 0, len(messages))
+	for i := range messages {
+		result = append(result, smsMessageResponse(&messages[i]))
+	}
+	response.Return(w, http.StatusOK, true, "SMS messages", result)
+}
+
+func smsMessageResponse(message *models.SMSMessage) dto.SMSMessageResponse {
+	return dto.SMSMessageResponse{
+		ID:                message.ID,
+		Phone:             message.Phone,
+		Provider:          message.Provider,
+		ProviderMessageID: message.ProviderMessageID,
+		Status:            message.Status,
+		ProviderStatus:    message.ProviderStatus,
+		Parts:             message.Parts,
+		Cost:              message.Cost,
+		Error:             message.Error,
+		CreatedAt:         message.CreatedAt.Format(time.RFC3339),
+		SentAt:            formatNullTime(message.SentAt),
+		DeliveredAt:       formatNullTime(message.DeliveredAt),
+		CheckedAt:         formatNullTime(message.CheckedAt),
+	}
+}
+
+func formatNullTime(t sql.NullTime) string {
+	if !t.Valid {
+		return ""
+	}
+	return t.Time.Format(time.RFC3339)
+}
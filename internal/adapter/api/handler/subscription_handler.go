@@ -0,0 +1,90 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	dto "sso/internal/adapter/api/handler/dto"
+	"sso/internal/logger"
+	"sso/internal/models"
+	"sso/internal/service"
+	"sso/pkg/apperror"
+	response "sso/pkg/response"
+)
+
+// SubscriptionHandler lets users see and change what they consented to be
+// contacted over. Changes are recorded with where they were made and synced
+// to Mindbox.
+type SubscriptionHandler struct {
+	ConsentService service.ConsentService
+	JWTService     service.JWTService
+	Logger         *logger.Logger
+}
+
+func NewSubscriptionHandler(s service.ConsentService, jwtService service.JWTService, logger *logger.Logger) *SubscriptionHandler {
+	return &SubscriptionHandler{
+		ConsentService: s,
+		JWTService:     jwtService,
+		Logger:         logger,
+	}
+}
+
+// Get returns the stored consent; points of contact the user never stated are
+// left out.
+func (h *SubscriptionHandler) Get(w http.ResponseWriter, r *http.Request) {
+	claims, _, ok := authenticateFirstParty(w, r, h.JWTService, "Subscriptions")
+	if !ok {
+		return
+	}
+
+	consent, err := h.ConsentService.Get(claims.UserID)
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "Error from ConsentService.Get", "user_id", claims.UserID, "error", err)
+		response.AppError(w, err)
+		return
+	}
+
+	response.Return(w, http.StatusOK, true, "Subscriptions", consentResponse(consent))
+}
+
+// Update changes the consent given in the body and leaves the rest as it is.
+func (h *SubscriptionHandler) Update(w http.ResponseWriter, r *http.Request) {
+	claims, _, ok := authenticateFirstParty(w, r, h.JWTService, "Subscriptions")
+	if !ok {
+		return
+	}
+
+	var req dto.Consent
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.Logger.ErrorContext(r.Context(), "Error decoding request body", "error", err)
+		response.AppError(w, apperror.ErrInvalidRequest)
+		return
+	}
+
+	platform := r.Header.Get("platform")
+	if platform == "" {
+		platform = "web"
+	}
+
+	consent, err := h.ConsentService.Update(claims.UserID, consentFromRequest(&req), service.ConsentOrigin{
+		Source:     models.ConsentSourceSettings,
+		Platform:   platform,
+		DeviceUUID: r.Header.Get("X-DeviceUUID"),
+		Agent:      r.UserAgent(),
+		IP:         clientIP(r),
+	})
+	if err != nil {
+		h.Logger.ErrorContext(r.Context(), "Error from ConsentService.Update", "user_id", claims.UserID, "error", err)
+		response.AppError(w, err)
+		return
+	}
+
+	response.Return(w, http.StatusOK, true, "Subscriptions updated", consentResponse(consent))
+}
+
+func consentResponse(consent service.Consent) dto.Consent {
+	return dto.Consent{
+		Email:   consent.Email,
+		SMS:     consent.SMS,
+		Webpush: consent.Webpush,
+	}
+}
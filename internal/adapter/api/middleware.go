@@ -0,0 +1,61 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"net/netip"
+	"regexp"
+	"sso/internal/logger"
+	"sso/pkg/apperror"
+	"sso/pkg/clientip"
+	response "sso/pkg/response"
+)
+
+// AdminAuth guards the admin API with the static key from ADMIN_API_KEY, sent
+// in the X-Admin-Key header. Without a configured key the admin API is off.
+func AdminAuth(key string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if key == "" {
+				response.AppError(w, apperror.ErrNotFound)
+				return
+			}
+			if subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Admin-Key")), []byte(key)) != 1 {
+				response.AppError(w, apperror.ErrUnauthorized.WithMessage("Invalid admin key"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestID keeps the X-Request-ID the ingress sent, or makes one up, echoes it
+// back and puts it in the request context so that log lines carry it.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !requestIDPattern.MatchString(id) {
+			b := make([]byte, 16)
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(logger.WithRequestID(r.Context(), id)))
+	})
+}
+
+// ClientIP resolves the client address once, believing X-Forwarded-For only
+// as far as trustedProxies appended it, and puts it in the request context.
+func ClientIP(trustedProxies []netip.Prefix) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := clientip.Resolve(r, trustedProxies)
+			next.ServeHTTP(w, r.WithContext(clientip.WithIP(r.Context(), ip)))
+		})
+	}
+}
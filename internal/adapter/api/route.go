@@ -10,8 +10,23 @@ import (
 	"github.com/go-chi/cors"
 )
 
-func NewRouter(verificationHandler *api.VerificationHandler, cfg *config.Config) http.Handler {
+type Handlers struct {
+	Verification  *api.VerificationHandler
+	JWKS          *api.JWKSHandler
+	OIDC          *api.OIDCHandler
+	Client        *api.ClientHandler
+	Introspection *api.IntrospectionHandler
+	Session       *api.SessionHandler
+	Fraud         *api.FraudHandler
+	SMS           *api.SMSHandler
+	Mindbox       *api.MindboxHandler
+	Subscription  *api.SubscriptionHandler
+}
+
+func NewRouter(handlers *Handlers, cfg *config.Config) http.Handler {
 	r := chi.NewRouter()
+	r.Use(RequestID)
+	r.Use(ClientIP(cfg.Proxy.TrustedProxies))
 
 	if cfg.AppEnv == "local" {
 		r.Use(func(next http.Handler) http.Handler {
@@ -26,21 +41,66 @@ func NewRouter(verificationHandler *api.VerificationHandler, cfg *config.Config)
 			})
 		})
 	}
-	corsConfig := config.GetCORSConfig(cfg)
-	corsMiddleware := cors.New(cors.Options{
+	corsConfig := config.GetCORSConfig(cfg, handlers.Client.ClientService.IsOriginAllowed)
+	corsOptions := cors.Options{
 		AllowedOrigins:   corsConfig.AllowedOrigins,
 		AllowedMethods:   corsConfig.AllowedMethods,
 		AllowedHeaders:   corsConfig.AllowedHeaders,
 		ExposedHeaders:   corsConfig.ExposedHeaders,
 		AllowCredentials: corsConfig.AllowCredentials,
 		MaxAge:           corsConfig.MaxAge,
-	})
+	}
+	if corsConfig.AllowOriginFunc != nil {
+		corsOptions.AllowOriginFunc = func(_ *http.Request, origin string) bool {
+			return corsConfig.AllowOriginFunc(origin)
+		}
+	}
+	corsMiddleware := cors.New(corsOptions)
 
 	r.Use(corsMiddleware.Handler)
 
-	r.Post("/verification", verificationHandler.Verification)
-	r.Post("/login", verificationHandler.Login)
-	r.Post("/logout", verificationHandler.Logout)
+	r.Post("/verification", handlers.Verification.Verification)
+	r.Post("/login", handlers.Verification.Login)
+	r.Post("/logout", handlers.Verification.Logout)
+	r.Post("/token/refresh", handlers.Verification.Refresh)
+
+	r.Get("/sessions", handlers.Session.List)
+	r.Delete("/sessions", handlers.Session.RevokeOthers)
+	r.Delete("/sessions/{sessionID}", handlers.Session.Revoke)
+
+	r.Get("/subscriptions", handlers.Subscription.Get)
+	r.Put("/subscriptions", handlers.Subscription.Update)
+
+	r.Get("/.well-known/jwks.json", handlers.JWKS.JWKS)
+	if handlers.OIDC != nil {
+		r.Get("/.well-known/openid-configuration", handlers.OIDC.Discovery)
+		r.Get("/authorize", handlers.OIDC.Authorize)
+		r.Post("/authorize", handlers.OIDC.CompleteAuthorization)
+		r.Post("/token", handlers.OIDC.Token)
+		r.Get("/userinfo", handlers.OIDC.UserInfo)
+		r.Post("/userinfo", handlers.OIDC.UserInfo)
+	}
+	r.Post("/introspect", handlers.Introspection.Introspect)
+
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(AdminAuth(cfg.AdminAPIKey))
+
+		r.Get("/clients", handlers.Client.List)
+		r.Post("/clients", handlers.Client.Create)
+		r.Get("/clients/{clientID}", handlers.Client.Get)
+		r.Put("/clients/{clientID}", handlers.Client.Update)
+		r.Delete("/clients/{clientID}", handlers.Client.Deactivate)
+		r.Post("/clients/{clientID}/secret", handlers.Client.RotateSecret)
+
+		r.Get("/fraud/prefixes", handlers.Fraud.List)
+		r.Put("/fraud/prefixes/{prefix}", handlers.Fraud.Set)
+		r.Delete("/fraud/prefixes/{prefix}", handlers.Fraud.Delete)
+
+		r.Get("/sms", handlers.SMS.History)
+
+		r.Get("/mindbox/outbox", handlers.Mindbox.Outbox)
+		r.Post("/mindbox/outbox/{eventID}/replay", handlers.Mindbox.Replay)
+	})
 
 	return r
 }
@@ -2,13 +2,12 @@ package api
 
 import (
 	"net/http"
-	handler "sso/internal/adapter/api/handler"
 	"sso/internal/config"
 	"sso/internal/logger"
 )
 
-func StartServer(handler *handler.VerificationHandler, cfg *config.Config, logger *logger.Logger) {
-	router := NewRouter(handler, cfg)
+func StartServer(handlers *Handlers, cfg *config.Config, logger *logger.Logger) {
+	router := NewRouter(handlers, cfg)
 
 	port := cfg.ServerPort
 	if port == "" {
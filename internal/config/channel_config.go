@@ -0,0 +1,29 @@
+package config
+
+// ChannelConfig enables and configures the channels a verification code can be
+// sent through.
+type ChannelConfig struct {
+	Enabled []string `env:"OTP_CHANNELS" env-default:"sms"`
+
+	// Message texts come from TemplatesFile. SMSTemplate and VoiceTemplate are
+	// used when it has no template for a channel in the wanted or the default
+	// language.
+	TemplatesFile string `env:"OTP_TEMPLATES_FILE" env-default:"./templates/otp.yaml"`
+	DefaultLang   string `env:"OTP_DEFAULT_LANG" env-default:"ru"`
+	SMSTemplate   string `env:"OTP_SMS_TEMPLATE" env-default:"{code} код доступа для авторизации {signature}"`
+
+	// Voice and flash calls are placed through SMSC with the SMS credentials.
+	VoiceTemplate string `env:"OTP_VOICE_TEMPLATE" env-default:"Ваш код: {code}"`
+
+	// WhatsApp Cloud API; the template must be an approved authentication
+	// template whose body takes the code as its only parameter.
+	WhatsAppURL           string `env:"WHATSAPP_API_URL" env-default:"https://graph.facebook.com/v19.0"`
+	WhatsAppPhoneNumberID string `env:"WHATSAPP_PHONE_NUMBER_ID"`
+	WhatsAppToken         string `env:"WHATSAPP_TOKEN"`
+	WhatsAppTemplate      string `env:"WHATSAPP_TEMPLATE" env-default:"otp"`
+	WhatsAppLanguage      string `env:"WHATSAPP_LANGUAGE" env-default:"ru"`
+
+	// Telegram Gateway API delivers codes by phone number through Telegram.
+	TelegramGatewayURL   string `env:"TELEGRAM_GATEWAY_URL" env-default:"https://gatewayapi.telegram.org"`
+	TelegramGatewayToken string `env:"TELEGRAM_GATEWAY_TOKEN"`
+}
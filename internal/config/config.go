@@ -20,15 +20,32 @@ type Config struct {
 
 	ServerPort string `env:"SERVER_PORT" required:"true"`
 
+	Proxy ProxyConfig
+
+	Log LogConfig
+
 	CORS CORSConfig
 
 	JWT JWTConfig
 
-	SMSCLogin    string `env:"SMSC_LOGIN" required:"true"`
-	SMSCPassword string `env:"SMSC_PASSWORD" required:"true"`
+	SMS SMSConfig
 
 	Mindbox MindboxConfig
 
+	OIDC OIDCConfig
+
+	Session SessionConfig
+
+	Phone PhoneConfig
+
+	OTP      OTPConfig
+	Channels ChannelConfig
+
+	Fraud   FraudConfig
+	Captcha CaptchaConfig
+
+	AdminAPIKey string `env:"ADMIN_API_KEY"`
+
 	TLS TLSConfig
 }
 
@@ -44,7 +61,16 @@ type MysqlConfig struct {
 }
 
 type JWTConfig struct {
-	SecretKey string `env:"JWT_SECRET_KEY" required:"true"`
+	SecretKey      string        `env:"JWT_SECRET_KEY"`
+	SigningAlg     string        `env:"JWT_SIGNING_ALG" env-default:"HS256"`
+	PrivateKeyPath string        `env:"JWT_PRIVATE_KEY_PATH"`
+	KeyID          string        `env:"JWT_KEY_ID"`
+	AccessTTL      time.Duration `env:"JWT_ACCESS_TTL" env-default:"15m"`
+	RefreshTTL     time.Duration `env:"JWT_REFRESH_TTL" env-default:"720h"`
+
+	KeysDir             string        `env:"JWT_KEYS_DIR"`
+	KeyRotationInterval time.Duration `env:"JWT_KEY_ROTATION_INTERVAL" env-default:"720h"`
+	KeyGracePeriod      time.Duration `env:"JWT_KEY_GRACE_PERIOD" env-default:"24h"`
 }
 
 type CORSConfig struct {
@@ -54,11 +80,13 @@ type CORSConfig struct {
 	ExposedHeaders   []string `env:"CORS_EXPOSED_HEADERS" env-default:"Link"`
 	AllowCredentials bool     `env:"CORS_ALLOW_CREDENTIALS" env-default:"true"`
 	MaxAge           int      `env:"CORS_MAX_AGE" env-default:"300"`
+
+	// AllowOriginFunc, when set, decides on origins instead of AllowedOrigins.
+	AllowOriginFunc func(origin string) bool
 }
 
 type TLSConfig struct {
-	SkipVerify bool          `env:"TLS_SKIP_VERIFY" env-default:"false"`
-	Timeout    time.Duration `env:"HTTP_TIMEOUT" env-default:"30s"`
+	SkipVerify bool `env:"TLS_SKIP_VERIFY" env-default:"false"`
 }
 
 func Load() *Config {
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"slices"
 	"strings"
 )
 
@@ -25,30 +26,28 @@ func ParseCORSHeaders(headers string) []string {
 	return strings.Split(headers, ",")
 }
 
-func GetCORSConfig(cfg *Config) CORSConfig {
+// GetCORSConfig returns the CORS policy for the environment. Outside local
+// development, browsers may only call the service from origins of registered
+// OAuth clients; isClientOrigin reports whether origin belongs to one.
+func GetCORSConfig(cfg *Config, isClientOrigin func(origin string) bool) CORSConfig {
 	switch cfg.AppEnv {
 	case "local", "dev":
+		localOrigins := []string{"http://localhost:3000", "http://localhost:8080", "http://localhost:5173"}
 		return CORSConfig{
-			AllowedOrigins:   []string{"http://localhost:3000", "http://localhost:8080", "http://localhost:5173"},
+			AllowedOrigins: localOrigins,
+			AllowOriginFunc: func(origin string) bool {
+				return slices.Contains(localOrigins, origin) || isClientOrigin(origin)
+			},
 			AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 			AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-DeviceUUID", "X-Platform"},
 			ExposedHeaders:   []string{"Link"},
 			AllowCredentials: true,
 			MaxAge:           300,
 		}
-	case "stage":
+	case "stage", "prod":
 		return CORSConfig{
-			AllowedOrigins:   []string{"https://stage.yourdomain.com"},
-			AllowedMethods:   []string{"POST", "OPTIONS"},
-			AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-DeviceUUID", "X-Platform"},
-			ExposedHeaders:   []string{"Link"},
-			AllowCredentials: true,
-			MaxAge:           300,
-		}
-	case "prod":
-		return CORSConfig{
-			AllowedOrigins:   []string{"https://yourdomain.com"},
-			AllowedMethods:   []string{"POST", "OPTIONS"},
+			AllowOriginFunc:  isClientOrigin,
+			AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 			AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-DeviceUUID", "X-Platform"},
 			ExposedHeaders:   []string{"Link"},
 			AllowCredentials: true,
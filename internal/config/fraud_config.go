@@ -0,0 +1,25 @@
+package config
+
+import "time"
+
+// FraudConfig tunes SMS pumping detection. Conversion is the share of sent
+// codes that end in a login within Window; prefixes whose conversion falls
+// below a threshold are challenged or blocked for RuleTTL.
+type FraudConfig struct {
+	Enabled             bool          `env:"FRAUD_ENABLED" env-default:"true"`
+	PrefixLength        int           `env:"FRAUD_PREFIX_LENGTH" env-default:"5"`
+	Window              time.Duration `env:"FRAUD_WINDOW" env-default:"24h"`
+	MinSamples          int           `env:"FRAUD_MIN_SAMPLES" env-default:"20"`
+	ChallengeConversion float64       `env:"FRAUD_CHALLENGE_CONVERSION" env-default:"0.3"`
+	BlockConversion     float64       `env:"FRAUD_BLOCK_CONVERSION" env-default:"0.1"`
+	RuleTTL             time.Duration `env:"FRAUD_RULE_TTL" env-default:"24h"`
+}
+
+// CaptchaConfig points at a reCAPTCHA/hCaptcha/Turnstile compatible
+// siteverify endpoint. Without a URL challenges are skipped and the code is
+// sent; blocking rules still apply.
+type CaptchaConfig struct {
+	VerifyURL string        `env:"CAPTCHA_VERIFY_URL"`
+	Secret    string        `env:"CAPTCHA_SECRET"`
+	Timeout   time.Duration `env:"CAPTCHA_TIMEOUT" env-default:"5s"`
+}
@@ -0,0 +1,13 @@
+package config
+
+import "time"
+
+// HTTPClientConfig limits the outbound requests to one integration. It is
+// read with the integration's prefix, e.g. MINDBOX_MAX_CONCURRENT.
+// MaxConcurrent caps the requests in flight; BreakerFailures consecutive
+// failures stop all requests for BreakerCooldown. Zero disables either.
+type HTTPClientConfig struct {
+	MaxConcurrent   int           `env:"MAX_CONCURRENT" env-default:"20"`
+	BreakerFailures int           `env:"BREAKER_FAILURES" env-default:"5"`
+	BreakerCooldown time.Duration `env:"BREAKER_COOLDOWN" env-default:"30s"`
+}
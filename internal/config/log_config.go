@@ -0,0 +1,23 @@
+package config
+
+import "time"
+
+type LogConfig struct {
+	// Format is console or json and Level one of debug, info, warn and error;
+	// left empty they follow APP_ENV. File, when set, additionally receives
+	// JSON lines and is rotated at FileMaxSizeMB.
+	Format         string        `env:"LOG_FORMAT"`
+	Level          string        `env:"LOG_LEVEL"`
+	File           string        `env:"LOG_FILE"`
+	FileMaxSizeMB  int           `env:"LOG_FILE_MAX_SIZE_MB" env-default:"100"`
+	FileMaxBackups int           `env:"LOG_FILE_MAX_BACKUPS" env-default:"7"`
+	FileMaxAge     time.Duration `env:"LOG_FILE_MAX_AGE" env-default:"168h"`
+
+	// Attributes with one of RedactKeys as key are masked whatever their value;
+	// RedactPatterns name the values (phone, email, jwt, bearer, query) masked
+	// wherever they appear. Unmask turns masking off for debugging and is
+	// ignored outside local and dev.
+	RedactKeys     []string `env:"LOG_REDACT_KEYS" env-default:"code,token,access_token,refresh_token,id_token,password,secret,client_secret,authorization,captcha_token,phone"`
+	RedactPatterns []string `env:"LOG_REDACT_PATTERNS" env-default:"phone,email,jwt,bearer,query"`
+	Unmask         bool     `env:"LOG_UNMASK" env-default:"false"`
+}
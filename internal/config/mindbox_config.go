@@ -1,5 +1,7 @@
 package config
 
+import "time"
+
 type MindboxConfig struct {
 	Url             string `env:"MINDBOX_URL" required:"true"`
 	OperationPrefix string `env:"MINDBOX_OPERATION_PREFIX" required:"true"`
@@ -15,4 +17,16 @@ type MindboxConfig struct {
 		Auth       string `env:"MINDBOX_WEB_AUTHORIZATION" required:"true"`
 		EndpointID string `env:"MINDBOX_WEB_ENDPOINT_ID" required:"true"`
 	}
+
+	Timeout time.Duration    `env:"MINDBOX_TIMEOUT" env-default:"10s"`
+	HTTP    HTTPClientConfig `env-prefix:"MINDBOX_"`
+
+	// Operations go through the mindbox_outbox table. A failed one is retried
+	// after OutboxBackoff, doubling up to OutboxBackoffMax, and is dead after
+	// OutboxMaxAttempts. OutboxLease is how long a worker may hold one.
+	OutboxBatch       int           `env:"MINDBOX_OUTBOX_BATCH" env-default:"100"`
+	OutboxMaxAttempts int           `env:"MINDBOX_OUTBOX_MAX_ATTEMPTS" env-default:"10"`
+	OutboxBackoff     time.Duration `env:"MINDBOX_OUTBOX_BACKOFF" env-default:"30s"`
+	OutboxBackoffMax  time.Duration `env:"MINDBOX_OUTBOX_BACKOFF_MAX" env-default:"6h"`
+	OutboxLease       time.Duration `env:"MINDBOX_OUTBOX_LEASE" env-default:"5m"`
 }
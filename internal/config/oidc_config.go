@@ -0,0 +1,11 @@
+package config
+
+import "time"
+
+type OIDCConfig struct {
+	Issuer         string        `env:"OIDC_ISSUER" env-default:"http://localhost:4053"`
+	LoginURL       string        `env:"OIDC_LOGIN_URL"`
+	AuthRequestTTL time.Duration `env:"OIDC_AUTH_REQUEST_TTL" env-default:"10m"`
+	AuthCodeTTL    time.Duration `env:"OIDC_AUTH_CODE_TTL" env-default:"1m"`
+	IDTokenTTL     time.Duration `env:"OIDC_ID_TOKEN_TTL" env-default:"1h"`
+}
@@ -0,0 +1,60 @@
+package config
+
+import "time"
+
+type OTPConfig struct {
+	// CodeTTL is how long a verification session, and the code in it, stays
+	// valid. With BindIP the code must be entered from the IP it was requested
+	// from; otherwise an IP change is only logged, as mobile clients switch
+	// networks.
+	CodeTTL time.Duration `env:"OTP_CODE_TTL" env-default:"5m"`
+	BindIP  bool          `env:"OTP_BIND_IP" env-default:"false"`
+
+	// MaxAttempts wrong codes per phone within AttemptWindow invalidate the
+	// code and lock the phone out; MaxAttemptsPerIP does the same per IP.
+	MaxAttempts      int           `env:"OTP_MAX_ATTEMPTS" env-default:"5"`
+	MaxAttemptsPerIP int           `env:"OTP_MAX_ATTEMPTS_PER_IP" env-default:"20"`
+	AttemptWindow    time.Duration `env:"OTP_ATTEMPT_WINDOW" env-default:"15m"`
+
+	// Every further lockout within LockoutMemory doubles, from LockoutBase up
+	// to LockoutMax.
+	LockoutBase   time.Duration `env:"OTP_LOCKOUT_BASE" env-default:"5m"`
+	LockoutMax    time.Duration `env:"OTP_LOCKOUT_MAX" env-default:"24h"`
+	LockoutMemory time.Duration `env:"OTP_LOCKOUT_MEMORY" env-default:"24h"`
+
+	// ResendCooldown is the minimum delay between two codes for one phone.
+	// The limits below cap codes sent over a sliding window; zero disables one.
+	ResendCooldown    time.Duration `env:"OTP_RESEND_COOLDOWN" env-default:"60s"`
+	PhoneHourlyLimit  int           `env:"OTP_PHONE_HOURLY_LIMIT" env-default:"5"`
+	PhoneDailyLimit   int           `env:"OTP_PHONE_DAILY_LIMIT" env-default:"10"`
+	IPHourlyLimit     int           `env:"OTP_IP_HOURLY_LIMIT" env-default:"30"`
+	DeviceHourlyLimit int           `env:"OTP_DEVICE_HOURLY_LIMIT" env-default:"10"`
+
+	// Per-channel overrides of the phone cooldown and limits, e.g.
+	// "voice:120s,flashcall:90s". The IP and device limits are shared by all
+	// channels.
+	ChannelCooldowns    map[string]time.Duration `env:"OTP_CHANNEL_RESEND_COOLDOWNS"`
+	ChannelHourlyLimits map[string]int           `env:"OTP_CHANNEL_PHONE_HOURLY_LIMITS"`
+	ChannelDailyLimits  map[string]int           `env:"OTP_CHANNEL_PHONE_DAILY_LIMITS"`
+}
+
+func (c OTPConfig) Cooldown(channel string) time.Duration {
+	if d, ok := c.ChannelCooldowns[channel]; ok {
+		return d
+	}
+	return c.ResendCooldown
+}
+
+func (c OTPConfig) HourlyLimit(channel string) int {
+	if n, ok := c.ChannelHourlyLimits[channel]; ok {
+		return n
+	}
+	return c.PhoneHourlyLimit
+}
+
+func (c OTPConfig) DailyLimit(channel string) int {
+	if n, ok := c.ChannelDailyLimits[channel]; ok {
+		return n
+	}
+	return c.PhoneDailyLimit
+}
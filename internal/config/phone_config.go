@@ -0,0 +1,9 @@
+package config
+
+// PhoneConfig controls which phone numbers can sign in. Numbers are stored in
+// E.164; numbers written without a country code belong to DefaultRegion.
+type PhoneConfig struct {
+	AllowedCountries []string `env:"PHONE_ALLOWED_COUNTRIES" env-default:"KZ,RU"`
+	DefaultRegion    string   `env:"PHONE_DEFAULT_REGION" env-default:"KZ"`
+	RequireMobile    bool     `env:"PHONE_REQUIRE_MOBILE" env-default:"true"`
+}
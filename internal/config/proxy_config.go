@@ -0,0 +1,42 @@
+package config
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// ProxyConfig lists the reverse proxies in front of the service. Only the
+// X-Forwarded-For hops they appended are believed; the rest is whatever the
+// client sent.
+type ProxyConfig struct {
+	TrustedProxies Prefixes `env:"TRUSTED_PROXIES" env-default:"127.0.0.0/8,10.0.0.0/8,172.16.0.0/12,192.168.0.0/16,::1/128,fc00::/7"`
+}
+
+// Prefixes is a comma separated list of CIDR prefixes or single addresses.
+type Prefixes []netip.Prefix
+
+func (p *Prefixes) SetValue(s string) error {
+	var prefixes Prefixes
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			addr, err := netip.ParseAddr(item)
+			if err != nil {
+				return fmt.Errorf("invalid address %q: %w", item, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			return fmt.Errorf("invalid prefix %q: %w", item, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	*p = prefixes
+	return nil
+}
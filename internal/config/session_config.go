@@ -0,0 +1,7 @@
+package config
+
+type SessionConfig struct {
+	// MaxActive caps concurrent sessions per user; the oldest one is evicted
+	// when a login goes over it. Zero means unlimited.
+	MaxActive int `env:"SESSION_MAX_ACTIVE" env-default:"10"`
+}
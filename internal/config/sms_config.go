@@ -0,0 +1,38 @@
+package config
+
+import "time"
+
+// SMSConfig selects the SMS providers. Providers is the default failover
+// order; Routes overrides it per country prefix of the normalized phone, e.g.
+// "7:smsc|http,998:http" sends +7 numbers through smsc first and +998 numbers
+// through the HTTP gateway only. The longest matching prefix wins.
+type SMSConfig struct {
+	Providers []string          `env:"SMS_PROVIDERS" env-default:"smsc"`
+	Routes    map[string]string `env:"SMS_ROUTES"`
+	Timeout   time.Duration     `env:"SMS_TIMEOUT" env-default:"10s"`
+
+	// Delivery of sent messages is polled every minute, StatusBatch at a
+	// time, for up to StatusWindow after they were sent.
+	StatusWindow time.Duration `env:"SMS_STATUS_WINDOW" env-default:"24h"`
+	StatusBatch  int           `env:"SMS_STATUS_BATCH" env-default:"100"`
+
+	SMSCLogin    string `env:"SMSC_LOGIN"`
+	SMSCPassword string `env:"SMSC_PASSWORD"`
+	SMSCURL      string `env:"SMSC_URL" env-default:"https://smsc.kz/sys/send.php"`
+	// SMSCHTTP is shared by SMS, voice and flash calls, as they all go to
+	// SMSC.
+	SMSCHTTP HTTPClientConfig `env-prefix:"SMSC_"`
+
+	// The generic gateway sends PhoneParam and TextParam as a JSON body or a
+	// form, plus any static Headers such as an API key.
+	HTTPURL        string            `env:"SMS_HTTP_URL"`
+	HTTPMethod     string            `env:"SMS_HTTP_METHOD" env-default:"POST"`
+	HTTPFormat     string            `env:"SMS_HTTP_FORMAT" env-default:"json"`
+	HTTPPhoneParam string            `env:"SMS_HTTP_PHONE_PARAM" env-default:"phone"`
+	HTTPTextParam  string            `env:"SMS_HTTP_TEXT_PARAM" env-default:"text"`
+	HTTPHeaders    map[string]string `env:"SMS_HTTP_HEADERS"`
+
+	// ConsoleFile makes the console provider append messages to a file
+	// instead of logging them.
+	ConsoleFile string `env:"SMS_CONSOLE_FILE"`
+}
@@ -16,6 +16,7 @@ type Container struct {
 	cacheContainer   *containers.CacheContainer
 	repoContainer    *containers.RepositoryContainer
 	serviceContainer *containers.ServiceContainer
+	schedContainer   *containers.SchedulerContainer
 }
 
 func NewContainer(cfg *config.Config) (*Container, error) {
@@ -23,7 +24,10 @@ func NewContainer(cfg *config.Config) (*Container, error) {
 		config: cfg,
 	}
 
-	loggerContainer := containers.NewLoggerContainer(cfg)
+	loggerContainer, err := containers.NewLoggerContainer(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create logger container: %w", err)
+	}
 	container.loggerContainer = loggerContainer
 
 	dbContainer, err := containers.NewDatabaseContainer(cfg, loggerContainer.Logger)
@@ -60,6 +64,13 @@ func NewContainer(cfg *config.Config) (*Container, error) {
 	}
 	container.serviceContainer = serviceContainer
 
+	schedContainer, err := containers.NewSchedulerContainer(serviceContainer, loggerContainer.Logger)
+	if err != nil {
+		container.Close()
+		return nil, fmt.Errorf("failed to create scheduler container: %w", err)
+	}
+	container.schedContainer = schedContainer
+
 	return container, nil
 }
 
@@ -67,11 +78,53 @@ func (c *Container) GetSSOService() service.SSOService {
 	return c.serviceContainer.GetSSOService()
 }
 
+func (c *Container) GetJWTService() service.JWTService {
+	return c.serviceContainer.GetJWTService()
+}
+
+func (c *Container) GetOIDCService() service.OIDCService {
+	return c.serviceContainer.GetOIDCService()
+}
+
+func (c *Container) GetClientService() service.ClientService {
+	return c.serviceContainer.GetClientService()
+}
+
+func (c *Container) GetIntrospectionService() service.IntrospectionService {
+	return c.serviceContainer.GetIntrospectionService()
+}
+
+func (c *Container) GetSessionService() service.SessionService {
+	return c.serviceContainer.GetSessionService()
+}
+
+func (c *Container) GetFraudService() service.FraudService {
+	return c.serviceContainer.GetFraudService()
+}
+
+func (c *Container) GetSMSService() service.SMSService {
+	return c.serviceContainer.GetSMSService()
+}
+
+func (c *Container) GetMindboxOutboxService() service.MindboxOutboxService {
+	return c.serviceContainer.GetMindboxOutboxService()
+}
+
+func (c *Container) GetConsentService() service.ConsentService {
+	return c.serviceContainer.GetConsentService()
+}
+
 func (c *Container) GetLogger() *logger.Logger {
 	return c.loggerContainer.Logger
 }
 
 func (c *Container) Close() {
+	if c.schedContainer != nil {
+		if err := c.schedContainer.Close(); err != nil {
+			c.loggerContainer.Logger.Error("Error stopping scheduler", "error", err)
+		}
+	}
+
 	if c.redisContainer != nil {
 		if err := c.redisContainer.Close(); err != nil {
 			c.loggerContainer.Logger.Error("Error closing Redis connection", "error", err)
@@ -83,4 +136,8 @@ func (c *Container) Close() {
 			c.loggerContainer.Logger.Error("Error closing database connection", "error", err)
 		}
 	}
+
+	if c.loggerContainer != nil {
+		c.loggerContainer.Close()
+	}
 }
@@ -1,6 +1,9 @@
 package containers
 
 import (
+	"fmt"
+	"log/slog"
+
 	"sso/internal/config"
 	"sso/internal/logger"
 )
@@ -9,26 +12,81 @@ type LoggerContainer struct {
 	Logger *logger.Logger
 }
 
-func NewLoggerContainer(cfg *config.Config) *LoggerContainer {
-	log := setupLogger(cfg)
+func NewLoggerContainer(cfg *config.Config) (*LoggerContainer, error) {
+	log, err := setupLogger(cfg)
+	if err != nil {
+		return nil, err
+	}
 	return &LoggerContainer{
 		Logger: log,
-	}
+	}, nil
+}
+
+func (c *LoggerContainer) Close() error {
+	return c.Logger.Close()
 }
 
-func setupLogger(cfg *config.Config) *logger.Logger {
-	var log *logger.Logger
+func setupLogger(cfg *config.Config) (*logger.Logger, error) {
+	redactor, err := setupRedactor(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := logger.Options{
+		Redactor:       redactor,
+		File:           cfg.Log.File,
+		FileMaxSizeMB:  cfg.Log.FileMaxSizeMB,
+		FileMaxBackups: cfg.Log.FileMaxBackups,
+		FileMaxAge:     cfg.Log.FileMaxAge,
+	}
 	switch cfg.AppEnv {
 	case "local":
-		log = logger.NewLogger(true)
+		opts.Format, opts.Level = logger.FormatConsole, slog.LevelDebug
 	case "dev":
-		log = logger.NewLogger(false)
+		opts.Format, opts.Level = logger.FormatConsole, slog.LevelInfo
 	case "stage":
-		log = logger.NewLogger(false)
+		opts.Format, opts.Level = logger.FormatJSON, slog.LevelInfo
 	case "prod":
-		log = logger.NewLogger(false)
+		opts.Format, opts.Level = logger.FormatJSON, slog.LevelInfo
 	default:
-		log = logger.NewLogger(true)
+		opts.Format, opts.Level = logger.FormatConsole, slog.LevelDebug
+	}
+
+	if cfg.Log.Format != "" {
+		opts.Format = cfg.Log.Format
+	}
+	if cfg.Log.Level != "" {
+		opts.Level, err = logger.ParseLevel(cfg.Log.Level)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	log, err := logger.NewLogger(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create logger: %w", err)
+	}
+
+	if cfg.Log.Unmask {
+		if redactor == nil {
+			log.Warn("Log masking is disabled by LOG_UNMASK", "env", cfg.AppEnv)
+		} else {
+			log.Warn("LOG_UNMASK is ignored outside local and dev", "env", cfg.AppEnv)
+		}
+	}
+	return log, nil
+}
+
+// setupRedactor returns nil, which leaves logs unmasked, only when LOG_UNMASK
+// is set in an environment that never sees production data.
+func setupRedactor(cfg *config.Config) (*logger.Redactor, error) {
+	if cfg.Log.Unmask && (cfg.AppEnv == "local" || cfg.AppEnv == "dev") {
+		return nil, nil
+	}
+
+	redactor, err := logger.NewRedactor(cfg.Log.RedactKeys, cfg.Log.RedactPatterns)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log redaction config: %w", err)
 	}
-	return log
+	return redactor, nil
 }
@@ -9,11 +9,14 @@ import (
 )
 
 type RepositoryContainer struct {
-	TestAccountRepo repository.TestAccountRepository
-	UserRepo        repository.UserRepository
-	TokenRepo       repository.TokenRepository
-	UserMindBoxRepo repository.UserMindBoxRepository
-	logger          *logger.Logger
+	TestAccountRepo   repository.TestAccountRepository
+	UserRepo          repository.UserRepository
+	TokenRepo         repository.TokenRepository
+	UserMindBoxRepo   repository.UserMindBoxRepository
+	ClientRepo        repository.ClientRepository
+	SMSMessageRepo    repository.SMSMessageRepository
+	MindboxOutboxRepo repository.MindboxOutboxRepository
+	logger            *logger.Logger
 }
 
 func NewRepositoryContainer(db *sql.DB, redisClient *redis.Client, logger *logger.Logger) (*RepositoryContainer, error) {
@@ -25,6 +28,9 @@ func NewRepositoryContainer(db *sql.DB, redisClient *redis.Client, logger *logge
 	container.UserRepo = repository.NewUserRepository(db)
 	container.TokenRepo = repository.NewTokenRepository(db)
 	container.UserMindBoxRepo = repository.NewUserMindBoxRepository(db)
+	container.ClientRepo = repository.NewClientRepository(db)
+	container.SMSMessageRepo = repository.NewSMSMessageRepository(db)
+	container.MindboxOutboxRepo = repository.NewMindboxOutboxRepository(db)
 
 	logger.Debug("All repositories initialized successfully")
 	return container, nil
@@ -45,3 +51,15 @@ func (c *RepositoryContainer) GetTokenRepository() repository.TokenRepository {
 func (c *RepositoryContainer) GetUserMindBoxRepository() repository.UserMindBoxRepository {
 	return c.UserMindBoxRepo
 }
+
+func (c *RepositoryContainer) GetClientRepository() repository.ClientRepository {
+	return c.ClientRepo
+}
+
+func (c *RepositoryContainer) GetSMSMessageRepository() repository.SMSMessageRepository {
+	return c.SMSMessageRepo
+}
+
+func (c *RepositoryContainer) GetMindboxOutboxRepository() repository.MindboxOutboxRepository {
+	return c.MindboxOutboxRepo
+}
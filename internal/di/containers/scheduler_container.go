@@ -0,0 +1,68 @@
+package containers
+
+import (
+	"fmt"
+	"sso/internal/logger"
+	"sso/pkg/scheduler"
+)
+
+type SchedulerContainer struct {
+	Scheduler *scheduler.Scheduler
+	logger    *logger.Logger
+}
+
+func NewSchedulerContainer(serviceContainer *ServiceContainer, logger *logger.Logger) (*SchedulerContainer, error) {
+	s, err := scheduler.New()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start scheduler: %w", err)
+	}
+
+	container := &SchedulerContainer{
+		Scheduler: s,
+		logger:    logger,
+	}
+
+	jwtService := serviceContainer.GetJWTService()
+	err = s.EveryHour(func() {
+		if err := jwtService.RotateKeys(); err != nil {
+			logger.Error("JWT signing key rotation failed", "error", err)
+		}
+	})
+	if err != nil {
+		container.Close()
+		return nil, fmt.Errorf("failed to schedule key rotation: %w", err)
+	}
+
+	smsService := serviceContainer.GetSMSService()
+	err = s.EveryMinute(func() {
+		if err := smsService.CheckDeliveries(); err != nil {
+			logger.Error("SMS delivery status check failed", "error", err)
+		}
+	})
+	if err != nil {
+		container.Close()
+		return nil, fmt.Errorf("failed to schedule sms status checks: %w", err)
+	}
+
+	// Every five seconds, so that a new user reaches Mindbox soon after login.
+	mindboxOutbox := serviceContainer.GetMindboxOutboxService()
+	err = s.NewJob("*/5 * * * * *", func() {
+		if err := mindboxOutbox.Process(); err != nil {
+			logger.Error("Mindbox outbox processing failed", "error", err)
+		}
+	})
+	if err != nil {
+		container.Close()
+		return nil, fmt.Errorf("failed to schedule mindbox outbox: %w", err)
+	}
+
+	logger.Debug("All scheduled jobs registered successfully")
+	return container, nil
+}
+
+func (c *SchedulerContainer) Close() error {
+	if c.Scheduler != nil {
+		return c.Scheduler.Stop()
+	}
+	return nil
+}
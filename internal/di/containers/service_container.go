@@ -1,16 +1,29 @@
 package containers
 
 import (
+	"fmt"
 	"sso/internal/config"
 	"sso/internal/logger"
 	"sso/internal/repository"
 	"sso/internal/service"
+	"sso/pkg/httpclient"
+	"sso/pkg/mindbox"
+	"sso/pkg/phone"
+	"time"
 )
 
 type ServiceContainer struct {
-	ssoService service.SSOService
-	jwtService service.JWTService
-	logger     *logger.Logger
+	ssoService     service.SSOService
+	jwtService     service.JWTService
+	oidcService    service.OIDCService
+	clientService  service.ClientService
+	introspection  service.IntrospectionService
+	sessionService service.SessionService
+	fraudService   service.FraudService
+	smsService     service.SMSService
+	mindboxOutbox  service.MindboxOutboxService
+	consent        service.ConsentService
+	logger         *logger.Logger
 }
 
 func NewSSOService(
@@ -19,8 +32,15 @@ func NewSSOService(
 	tokenRepo repository.TokenRepository,
 	codeCache service.CacheService,
 	jwtService service.JWTService,
-	smsService service.SMSCService,
-	mindboxService service.AuthMindboxService,
+	channels service.OTPChannelRegistry,
+	templates service.OTPTemplates,
+	phones *phone.Parser,
+	mindboxOutbox service.MindboxOutboxService,
+	consent service.ConsentService,
+	sessionService service.SessionService,
+	fraudService service.FraudService,
+	refreshTTL time.Duration,
+	otpConfig config.OTPConfig,
 	logger *logger.Logger,
 ) service.SSOService {
 	return &service.SSOAuthService{
@@ -29,32 +49,158 @@ func NewSSOService(
 		TokenRepo:       tokenRepo,
 		CodeCache:       codeCache,
 		JWTService:      jwtService,
-		SMSService:      smsService,
-		MindboxService:  mindboxService,
+		Channels:        channels,
+		Templates:       templates,
+		Phones:          phones,
+		MindboxOutbox:   mindboxOutbox,
+		Consent:         consent,
+		Sessions:        sessionService,
+		Fraud:           fraudService,
+		RefreshTTL:      refreshTTL,
+		OTP:             otpConfig,
 		Logger:          logger,
 	}
 }
 
+// newHTTPClient returns the outbound client for one integration.
+func newHTTPClient(name string, timeout time.Duration, cfg config.HTTPClientConfig, skipVerify bool, logger *logger.Logger) *httpclient.Client {
+	return httpclient.New(httpclient.Options{
+		Name:               name,
+		Timeout:            timeout,
+		MaxConcurrent:      cfg.MaxConcurrent,
+		BreakerFailures:    cfg.BreakerFailures,
+		BreakerCooldown:    cfg.BreakerCooldown,
+		InsecureSkipVerify: skipVerify,
+		Logger:             logger.Logger,
+	})
+}
+
+// newMindboxClient returns the Mindbox client with an endpoint per platform.
+func newMindboxClient(cfg *config.Config, logger *logger.Logger) *mindbox.Client {
+	return mindbox.New(mindbox.Config{
+		URL:             cfg.Mindbox.Url,
+		OperationPrefix: cfg.Mindbox.OperationPrefix,
+		Endpoints: map[string]mindbox.Endpoint{
+			"android": {ID: cfg.Mindbox.Android.EndpointID, Auth: cfg.Mindbox.Android.Auth},
+			"ios":     {ID: cfg.Mindbox.IOS.EndpointID, Auth: cfg.Mindbox.IOS.Auth},
+			"web":     {ID: cfg.Mindbox.Web.EndpointID, Auth: cfg.Mindbox.Web.Auth},
+		},
+	}, newHTTPClient("mindbox", cfg.Mindbox.Timeout, cfg.Mindbox.HTTP, cfg.TLS.SkipVerify, logger))
+}
+
 func NewServiceContainer(repoContainer *RepositoryContainer, cacheContainer *CacheContainer, cfg *config.Config, logger *logger.Logger) (*ServiceContainer, error) {
 	container := &ServiceContainer{
 		logger: logger,
 	}
 
-	jwtService := service.NewJWTService(cfg.JWT.SecretKey, cacheContainer.GetCodeCache())
+	jwtService, err := service.NewJWTService(cfg.JWT, cacheContainer.GetCodeCache(), repoContainer.TokenRepo, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create jwt service: %w", err)
+	}
 	container.jwtService = jwtService
 
+	sessionService := service.NewSessionService(
+		repoContainer.TokenRepo,
+		cacheContainer.GetCodeCache(),
+		jwtService.AccessTTL(),
+		cfg.Session.MaxActive,
+		logger,
+	)
+	container.sessionService = sessionService
+
+	smscClient := newHTTPClient("smsc", cfg.SMS.Timeout, cfg.SMS.SMSCHTTP, cfg.TLS.SkipVerify, logger)
+	smsService, err := service.NewSMSService(cfg.SMS, smscClient, repoContainer.SMSMessageRepo, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sms service: %w", err)
+	}
+	container.smsService = smsService
+
+	phones, err := phone.NewParser(cfg.Phone.AllowedCountries, cfg.Phone.DefaultRegion, cfg.Phone.RequireMobile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create phone parser: %w", err)
+	}
+
+	templates, err := service.NewOTPTemplates(cfg.Channels, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load otp templates: %w", err)
+	}
+
+	channels, err := service.NewOTPChannelRegistry(cfg.Channels, cfg.SMS, smscClient, templates, smsService, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create otp channels: %w", err)
+	}
+
+	fraudService := service.NewFraudService(
+		cacheContainer.GetCodeCache(),
+		service.NewCaptchaVerifier(cfg.Captcha, logger),
+		cfg.Fraud,
+		logger,
+	)
+	container.fraudService = fraudService
+
+	mindboxOutbox := service.NewMindboxOutboxService(
+		repoContainer.MindboxOutboxRepo,
+		repoContainer.UserRepo,
+		service.NewAuthMindboxService(
+			logger,
+			repoContainer.UserRepo,
+			repoContainer.UserMindBoxRepo,
+			newMindboxClient(cfg, logger),
+		),
+		cfg.Mindbox,
+		logger,
+	)
+	container.mindboxOutbox = mindboxOutbox
+
+	consent := service.NewConsentService(repoContainer.UserMindBoxRepo, mindboxOutbox, logger)
+	container.consent = consent
+
 	ssoService := NewSSOService(
 		repoContainer.TestAccountRepo,
 		repoContainer.UserRepo,
 		repoContainer.TokenRepo,
 		cacheContainer.GetCodeCache(),
 		jwtService,
-		service.NewSMSCService(cfg.SMSCLogin, cfg.SMSCPassword, logger),
-		service.NewAuthMindboxService(logger, repoContainer.UserRepo, repoContainer.UserMindBoxRepo, cfg),
+		channels,
+		templates,
+		phones,
+		mindboxOutbox,
+		consent,
+		sessionService,
+		fraudService,
+		cfg.JWT.RefreshTTL,
+		cfg.OTP,
 		logger,
 	)
 	container.ssoService = ssoService
 
+	clientService := service.NewClientService(repoContainer.ClientRepo, logger)
+	container.clientService = clientService
+
+	// ID tokens signed with a shared secret could be verified, and forged, only
+	// with that secret, so OIDC needs a key pair published in JWKS.
+	if jwtService.Asymmetric() {
+		container.oidcService = service.NewOIDCService(
+			ssoService,
+			clientService,
+			jwtService,
+			repoContainer.UserRepo,
+			cacheContainer.GetCodeCache(),
+			cfg,
+			logger,
+		)
+	} else {
+		logger.Warn("OIDC is disabled: JWT_SIGNING_ALG must be RS256 or ES256 to sign ID tokens", "alg", cfg.JWT.SigningAlg)
+	}
+
+	container.introspection = service.NewIntrospectionService(
+		clientService,
+		jwtService,
+		repoContainer.TokenRepo,
+		cfg.OIDC.Issuer,
+		logger,
+	)
+
 	logger.Debug("All services initialized successfully")
 	return container, nil
 }
@@ -66,3 +212,36 @@ func (c *ServiceContainer) GetSSOService() service.SSOService {
 func (c *ServiceContainer) GetJWTService() service.JWTService {
 	return c.jwtService
 }
+
+// GetOIDCService returns nil when OIDC is disabled.
+func (c *ServiceContainer) GetOIDCService() service.OIDCService {
+	return c.oidcService
+}
+
+func (c *ServiceContainer) GetClientService() service.ClientService {
+	return c.clientService
+}
+
+func (c *ServiceContainer) GetIntrospectionService() service.IntrospectionService {
+	return c.introspection
+}
+
+func (c *ServiceContainer) GetSessionService() service.SessionService {
+	return c.sessionService
+}
+
+func (c *ServiceContainer) GetFraudService() service.FraudService {
+	return c.fraudService
+}
+
+func (c *ServiceContainer) GetSMSService() service.SMSService {
+	return c.smsService
+}
+
+func (c *ServiceContainer) GetMindboxOutboxService() service.MindboxOutboxService {
+	return c.mindboxOutbox
+}
+
+func (c *ServiceContainer) GetConsentService() service.ConsentService {
+	return c.consent
+}
@@ -3,24 +3,49 @@ package logger
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"io"
 	"log"
 	"log/slog"
 	"os"
 	"path/filepath"
 	"runtime"
-	"strconv"
+	"strings"
+	"time"
 
 	"github.com/fatih/color"
 )
 
-const runtimeCallerSkip = 4
+const (
+	FormatConsole = "console"
+	FormatJSON    = "json"
+)
 
 var currentDir = "."
 
+// Options configure a Logger. Console writes colored, human-readable lines to
+// stdout; JSON writes one object per line. File, when set, always receives
+// JSON and is rotated once it grows over MaxSizeMB.
+type Options struct {
+	Format   string
+	Level    slog.Level
+	Redactor *Redactor
+
+	File           string
+	FileMaxSizeMB  int
+	FileMaxBackups int
+	FileMaxAge     time.Duration
+}
+
 type Handler struct {
-	slog.Handler
+	level    slog.Level
 	lConsole *log.Logger
-	lFile    *log.Logger
+	json     slog.Handler
+	lFile    *rotatingFile
+	redactor *Redactor
+
+	attrs  []slog.Attr
+	prefix string
 }
 
 func init() {
@@ -31,23 +56,109 @@ func init() {
 	currentDir = dir
 }
 
-func NewHandler(debug bool) *Handler {
-	return &Handler{
-		Handler: slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
-			Level: slog.LevelDebug,
-		}),
-		lConsole: log.New(os.Stdout, "", 0),
+// NewHandler returns a Handler that masks records with opts.Redactor before
+// writing them; a nil redactor writes them unchanged.
+func NewHandler(opts Options) (*Handler, error) {
+	h := &Handler{
+		level:    opts.Level,
+		redactor: opts.Redactor,
+	}
+
+	var jsonOut []io.Writer
+	switch opts.Format {
+	case "", FormatConsole:
+		h.lConsole = log.New(os.Stdout, "", 0)
+	case FormatJSON:
+		jsonOut = append(jsonOut, os.Stdout)
+	default:
+		return nil, fmt.Errorf("unknown log format %q", opts.Format)
+	}
+
+	if opts.File != "" {
+		file, err := openRotatingFile(opts.File, opts.FileMaxSizeMB, opts.FileMaxBackups, opts.FileMaxAge)
+		if err != nil {
+			return nil, err
+		}
+		h.lFile = file
+		jsonOut = append(jsonOut, file)
+	}
+
+	if len(jsonOut) > 0 {
+		h.json = slog.NewJSONHandler(io.MultiWriter(jsonOut...), &slog.HandlerOptions{
+			Level: opts.Level,
+		})
 	}
+	return h, nil
+}
+
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
 }
 
 func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
-	err := h.console(r)
-	if err != nil {
-		return err
+	rec := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	if caller := recordCaller(r); caller != "" {
+		rec.AddAttrs(slog.String("caller", caller))
+	}
+	if id := RequestIDFromContext(ctx); id != "" {
+		rec.AddAttrs(slog.String("request_id", id))
+	}
+	rec.AddAttrs(h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		rec.AddAttrs(h.prefixed(a))
+		return true
+	})
+
+	if h.redactor != nil {
+		rec = h.redactor.Record(rec)
+	}
+
+	if h.lConsole != nil {
+		if err := h.console(rec); err != nil {
+			return err
+		}
+	}
+	if h.json != nil {
+		return h.json.Handle(ctx, rec)
 	}
 	return nil
 }
 
+// WithAttrs and WithGroup flatten groups into dotted keys so that both output
+// formats show them the same way.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	next := *h
+	next.attrs = append([]slog.Attr{}, h.attrs...)
+	for _, a := range attrs {
+		next.attrs = append(next.attrs, h.prefixed(a))
+	}
+	return &next
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	next := *h
+	next.prefix = h.prefix + name + "."
+	return &next
+}
+
+func (h *Handler) prefixed(a slog.Attr) slog.Attr {
+	if h.prefix == "" {
+		return a
+	}
+	return slog.Attr{Key: h.prefix + a.Key, Value: a.Value}
+}
+
+// Close flushes and closes the log file, if there is one.
+func (h *Handler) Close() error {
+	if h.lFile == nil {
+		return nil
+	}
+	return h.lFile.Close()
+}
+
 func (h *Handler) console(r slog.Record) error {
 	level := r.Level.String() + ":"
 	switch r.Level {
@@ -61,8 +172,13 @@ func (h *Handler) console(r slog.Record) error {
 		level = color.RedString(level)
 	}
 
+	var caller string
 	fields := make(map[string]interface{}, r.NumAttrs())
 	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == "caller" {
+			caller = a.Value.String()
+			return true
+		}
 		fields[a.Key] = a.Value.Any()
 		return true
 	})
@@ -72,18 +188,12 @@ func (h *Handler) console(r slog.Record) error {
 		return err
 	}
 
-	timeStr := r.Time.Format("[15:05:05]")
-	_, file, line, ok := runtime.Caller(runtimeCallerSkip)
-	if ok {
-		relPath, err := filepath.Rel(currentDir, file)
-		if err != nil {
-			relPath = file
-		}
-		h.lConsole.Printf("%s %s %s:%s [ %s ] %s \n",
+	timeStr := r.Time.Format("[15:04:05]")
+	if caller != "" {
+		h.lConsole.Printf("%s %s %s [ %s ] %s \n",
 			color.GreenString(timeStr),
 			level,
-			color.HiCyanString(relPath),
-			color.HiCyanString(strconv.Itoa(line)),
+			color.HiCyanString(caller),
 			color.CyanString(r.Message),
 			color.HiWhiteString(string(b)))
 	} else {
@@ -96,31 +206,97 @@ func (h *Handler) console(r slog.Record) error {
 	return nil
 }
 
+// recordCaller returns the file:line the record was logged from, relative to
+// the working directory.
+func recordCaller(r slog.Record) string {
+	if r.PC == 0 {
+		return ""
+	}
+	frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+	if frame.File == "" {
+		return ""
+	}
+	path, err := filepath.Rel(currentDir, frame.File)
+	if err != nil || strings.HasPrefix(path, "..") {
+		path = frame.File
+	}
+	return fmt.Sprintf("%s:%d", path, frame.Line)
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns ctx carrying the id of the request being served.
+// Records logged with that context get it as the request_id field.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
 type Logger struct {
 	*slog.Logger
+	handler *Handler
 }
 
-func NewLogger(debug bool) *Logger {
-	handler := NewHandler(debug)
+func NewLogger(opts Options) (*Logger, error) {
+	handler, err := NewHandler(opts)
+	if err != nil {
+		return nil, err
+	}
 	logger := slog.New(handler)
 
 	return &Logger{
-		Logger: logger,
+		Logger:  logger,
+		handler: handler,
+	}, nil
+}
+
+// ParseLevel accepts debug, info, warn and error.
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q", s)
 	}
+	return level, nil
+}
+
+func (l *Logger) Close() error {
+	return l.handler.Close()
 }
 
 func (l *Logger) Info(msg string, args ...any) {
-	l.Logger.Info(msg, args...)
+	l.log(slog.LevelInfo, msg, args...)
 }
 
 func (l *Logger) Error(msg string, args ...any) {
-	l.Logger.Error(msg, args...)
+	l.log(slog.LevelError, msg, args...)
 }
 
 func (l *Logger) Debug(msg string, args ...any) {
-	l.Logger.Debug(msg, args...)
+	l.log(slog.LevelDebug, msg, args...)
 }
 
 func (l *Logger) Warn(msg string, args ...any) {
-	l.Logger.Warn(msg, args...)
+	l.log(slog.LevelWarn, msg, args...)
+}
+
+// log records the caller of the method that called it, not this wrapper.
+// Records logged through Info, Warn, Error and Debug carry no request_id; code
+// serving a request logs with the Context variants instead.
+func (l *Logger) log(level slog.Level, msg string, args ...any) {
+	ctx := context.Background()
+	if !l.Enabled(ctx, level) {
+		return
+	}
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:])
+	r := slog.NewRecord(time.Now(), level, msg, pcs[0])
+	r.Add(args...)
+	_ = l.Handler().Handle(ctx, r)
 }
@@ -0,0 +1,165 @@
+package logger
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+var phonePattern = regexp.MustCompile(`(?:\+|\b)\d{10,15}\b`)
+
+// valuePatterns are the secrets and personal data that are masked wherever
+// they turn up in a message or a string attribute, by name.
+var valuePatterns = map[string]valuePattern{
+	"phone": {
+		re:   phonePattern,
+		mask: maskPhone,
+	},
+	"email": {
+		re:   regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+		mask: maskEmail,
+	},
+	"jwt": {
+		re:   regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+		mask: func(string) string { return redacted },
+	},
+	"query": {
+		re: regexp.MustCompile(`(?i)\b(?:psw|password|mes|code)=[^&\s"]*`),
+		mask: func(s string) string {
+			return s[:strings.IndexByte(s, '=')+1] + redacted
+		},
+	},
+	"bearer": {
+		re: regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9._~+/=-]+`),
+		mask: func(s string) string {
+			return s[:len("bearer")] + " " + redacted
+		},
+	},
+}
+
+type valuePattern struct {
+	re   *regexp.Regexp
+	mask func(string) string
+}
+
+// Redactor masks attributes whose key is in its key list, whatever their value,
+// and anything matching its value patterns inside messages and string values.
+// Keys match case-insensitively. Phone numbers are masked partially so that
+// log lines can still be told apart.
+type Redactor struct {
+	keys     map[string]bool
+	patterns []valuePattern
+}
+
+// NewRedactor returns a Redactor for keys and the named value patterns: phone,
+// email, jwt, bearer and query, the secret parameters of a query string.
+func NewRedactor(keys, patterns []string) (*Redactor, error) {
+	r := &Redactor{keys: make(map[string]bool, len(keys))}
+	for _, key := range keys {
+		key = strings.ToLower(strings.TrimSpace(key))
+		if key != "" {
+			r.keys[key] = true
+		}
+	}
+
+	for _, name := range patterns {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		pattern, ok := valuePatterns[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown log redaction pattern %q", name)
+		}
+		r.patterns = append(r.patterns, pattern)
+	}
+
+	return r, nil
+}
+
+// Record returns a copy of rec with its message and attributes redacted.
+func (r *Redactor) Record(rec slog.Record) slog.Record {
+	out := slog.NewRecord(rec.Time, rec.Level, r.String(rec.Message), rec.PC)
+	rec.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(r.Attr(a))
+		return true
+	})
+	return out
+}
+
+func (r *Redactor) Attr(a slog.Attr) slog.Attr {
+	v := a.Value.Resolve()
+
+	if v.Kind() == slog.KindGroup {
+		attrs := v.Group()
+		masked := make([]slog.Attr, len(attrs))
+		for i, attr := range attrs {
+			masked[i] = r.Attr(attr)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(masked...)}
+	}
+
+	if r.keys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, r.maskKeyValue(v))
+	}
+
+	switch v.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, r.String(v.String()))
+	case slog.KindAny:
+		switch x := v.Any().(type) {
+		case error:
+			return slog.String(a.Key, r.String(x.Error()))
+		case []byte:
+			return slog.String(a.Key, r.String(string(x)))
+		case fmt.Stringer:
+			return slog.String(a.Key, r.String(x.String()))
+		}
+	}
+	return slog.Attr{Key: a.Key, Value: v}
+}
+
+// maskKeyValue hides the value of a redacted key. Phone numbers keep a few
+// digits; empty values stay empty so that a missing value is still visible.
+func (r *Redactor) maskKeyValue(v slog.Value) string {
+	s := v.String()
+	if s == "" {
+		return ""
+	}
+	if phonePattern.FindString(s) == s {
+		return maskPhone(s)
+	}
+	return redacted
+}
+
+// String masks every value pattern match in s.
+func (r *Redactor) String(s string) string {
+	for _, p := range r.patterns {
+		s = p.re.ReplaceAllStringFunc(s, p.mask)
+	}
+	return s
+}
+
+// maskPhone keeps the first three and the last two digits.
+func maskPhone(s string) string {
+	prefix := ""
+	if strings.HasPrefix(s, "+") {
+		prefix, s = "+", s[1:]
+	}
+	if len(s) < 8 {
+		return prefix + strings.Repeat("*", len(s))
+	}
+	return prefix + s[:3] + strings.Repeat("*", len(s)-5) + s[len(s)-2:]
+}
+
+// maskEmail keeps the first letter of the local part and the domain.
+func maskEmail(s string) string {
+	at := strings.LastIndex(s, "@")
+	if at < 1 {
+		return redacted
+	}
+	return s[:1] + "***" + s[at:]
+}
@@ -0,0 +1,125 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const backupTimeFormat = "20060102T150405.000"
+
+// rotatingFile appends to path and, once a write would take it over maxSize,
+// renames it to path.<timestamp> and starts a new one. Only the newest
+// maxBackups rotated files younger than maxAge are kept; zero disables either
+// limit.
+type rotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	maxAge     time.Duration
+
+	file *os.File
+	size int64
+}
+
+func openRotatingFile(path string, maxSizeMB, maxBackups int, maxAge time.Duration) (*rotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	f := &rotatingFile{
+		path:       path,
+		maxSize:    int64(maxSizeMB) * 1024 * 1024,
+		maxBackups: maxBackups,
+		maxAge:     maxAge,
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file: %w", err)
+	}
+
+	backup := f.path + "." + time.Now().Format(backupTimeFormat)
+	if err := os.Rename(f.path, backup); err != nil {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	f.prune()
+	return nil
+}
+
+// prune removes rotated files over the backup count or age. Failures are
+// ignored: a leftover backup is not worth losing log lines over.
+func (f *rotatingFile) prune() {
+	backups, err := filepath.Glob(f.path + ".*")
+	if err != nil {
+		return
+	}
+
+	// The timestamp suffix sorts chronologically; newest first.
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+
+	kept := 0
+	for _, backup := range backups {
+		stamp, err := time.ParseInLocation(backupTimeFormat, strings.TrimPrefix(backup, f.path+"."), time.Local)
+		if err != nil {
+			continue
+		}
+		tooMany := f.maxBackups > 0 && kept >= f.maxBackups
+		tooOld := f.maxAge > 0 && time.Since(stamp) > f.maxAge
+		if tooMany || tooOld {
+			os.Remove(backup)
+			continue
+		}
+		kept++
+	}
+}
+
+func (f *rotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Close()
+}
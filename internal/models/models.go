@@ -2,6 +2,9 @@ package models
 
 import (
 	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
 )
 
 type User struct {
@@ -42,16 +45,74 @@ type TestAccount struct {
 	Code  sql.NullString `db:"code" json:"code,omitempty"`
 }
 
+// UserAccessToken is a login session. Token holds the latest access token of
+// the session and ExpireAt the expiry of its refresh token family.
 type UserAccessToken struct {
+	ID         int64          `db:"id" json:"id"`
+	UserID     sql.NullInt64  `db:"user_id" json:"user_id,omitempty"`
+	Token      sql.NullString `db:"token" json:"token,omitempty"`
+	FamilyID   sql.NullString `db:"family_id" json:"family_id,omitempty"`
+	ClientID   sql.NullString `db:"client_id" json:"client_id,omitempty"`
+	Agent      sql.NullString `db:"agent" json:"agent,omitempty"`
+	IP         sql.NullString `db:"ip" json:"ip,omitempty"`
+	Platform   sql.NullString `db:"platform" json:"platform,omitempty"`
+	DeviceUUID sql.NullString `db:"device_uuid" json:"device_uuid,omitempty"`
+	ExpireAt   sql.NullTime   `db:"expire_at" json:"expire_at,omitempty"`
+	LoginAt    sql.NullTime   `db:"login_at" json:"login_at,omitempty"`
+	LastSeenAt sql.NullTime   `db:"last_seen_at" json:"last_seen_at,omitempty"`
+	CreatedAt  sql.NullTime   `db:"created_at" json:"created_at,omitempty"`
+	UpdatedAt  sql.NullTime   `db:"updated_at" json:"updated_at,omitempty"`
+}
+
+type UserRefreshToken struct {
 	ID        int64          `db:"id" json:"id"`
-	UserID    sql.NullInt64  `db:"user_id" json:"user_id,omitempty"`
-	Token     sql.NullString `db:"token" json:"token,omitempty"`
+	UserID    int64          `db:"user_id" json:"user_id"`
+	TokenHash string         `db:"token_hash" json:"-"`
+	FamilyID  string         `db:"family_id" json:"family_id"`
+	ClientID  sql.NullString `db:"client_id" json:"client_id,omitempty"`
+	Scope     sql.NullString `db:"scope" json:"scope,omitempty"`
 	Agent     sql.NullString `db:"agent" json:"agent,omitempty"`
 	IP        sql.NullString `db:"ip" json:"ip,omitempty"`
-	ExpireAt  sql.NullTime   `db:"expire_at" json:"expire_at,omitempty"`
-	LoginAt   sql.NullTime   `db:"login_at" json:"login_at,omitempty"`
-	CreatedAt sql.NullTime   `db:"created_at" json:"created_at,omitempty"`
-	UpdatedAt sql.NullTime   `db:"updated_at" json:"updated_at,omitempty"`
+	ExpireAt  time.Time      `db:"expire_at" json:"expire_at"`
+	RotatedAt sql.NullTime   `db:"rotated_at" json:"rotated_at,omitempty"`
+	RevokedAt sql.NullTime   `db:"revoked_at" json:"revoked_at,omitempty"`
+	CreatedAt time.Time      `db:"created_at" json:"created_at"`
+}
+
+// Client is an OAuth2/OIDC relying party. RedirectURIs is a JSON array,
+// GrantTypes and Scopes are space separated as in OAuth2 requests.
+type Client struct {
+	ID               int64     `db:"id" json:"-"`
+	ClientID         string    `db:"client_id" json:"client_id"`
+	ClientSecretHash string    `db:"client_secret_hash" json:"-"`
+	Name             string    `db:"name" json:"name"`
+	RedirectURIs     string    `db:"redirect_uris" json:"-"`
+	GrantTypes       string    `db:"grant_types" json:"-"`
+	Scopes           string    `db:"scopes" json:"-"`
+	IsActive         bool      `db:"is_active" json:"is_active"`
+	CreatedAt        time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt        time.Time `db:"updated_at" json:"updated_at"`
+}
+
+func (c *Client) RedirectURIList() []string {
+	var uris []string
+	if err := json.Unmarshal([]byte(c.RedirectURIs), &uris); err != nil {
+		return nil
+	}
+	return uris
+}
+
+func (c *Client) GrantTypeList() []string {
+	return strings.Fields(c.GrantTypes)
+}
+
+func (c *Client) ScopeList() []string {
+	return strings.Fields(c.Scopes)
+}
+
+// IsConfidential reports whether the client must authenticate with a secret.
+func (c *Client) IsConfidential() bool {
+	return c.ClientSecretHash != ""
 }
 
 type UserMindBox struct {
@@ -60,16 +121,135 @@ type UserMindBox struct {
 	ConsentToMailings      sql.NullBool   `db:"consent_to_mailings" json:"consent_to_mailings,omitempty"`
 	LoyaltyProgramEnrolled sql.NullBool   `db:"loyalty_program_enrolled" json:"loyalty_program_enrolled,omitempty"`
 	MindBoxUserID          sql.NullString `db:"mind_box_user_id" json:"mind_box_user_id,omitempty"`
+	MindBoxStatus          sql.NullString `db:"mind_box_status" json:"mind_box_status,omitempty"`
+	MindBoxSyncedAt        sql.NullTime   `db:"mind_box_synced_at" json:"mind_box_synced_at,omitempty"`
 	IsPhoneConfirm         sql.NullBool   `db:"is_phone_confirm" json:"is_phone_confirm,omitempty"`
 	Barcode                sql.NullString `db:"barcode" json:"barcode,omitempty"`
 	RefPromo               sql.NullString `db:"ref_promo" json:"ref_promo,omitempty"`
 	RefPromoPharm          sql.NullString `db:"ref_promo_pharm" json:"ref_promo_pharm,omitempty"`
+	ConsentEmail           sql.NullBool   `db:"consent_email" json:"consent_email,omitempty"`
+	ConsentSMS             sql.NullBool   `db:"consent_sms" json:"consent_sms,omitempty"`
+	ConsentWebpush         sql.NullBool   `db:"consent_webpush" json:"consent_webpush,omitempty"`
+}
+
+// Consent returns the stored consent for channel; it is not valid when the
+// user never stated it.
+func (m *UserMindBox) Consent(channel string) sql.NullBool {
+	switch channel {
+	case ConsentEmail:
+		return m.ConsentEmail
+	case ConsentSMS:
+		return m.ConsentSMS
+	case ConsentWebpush:
+		return m.ConsentWebpush
+	}
+	return sql.NullBool{}
+}
+
+// Points of contact a user can consent to be reached over.
+const (
+	ConsentEmail   = "email"
+	ConsentSMS     = "sms"
+	ConsentWebpush = "webpush"
+)
+
+// ConsentChannels lists the points of contact in the order they are reported.
+var ConsentChannels = []string{ConsentEmail, ConsentSMS, ConsentWebpush}
+
+// Where a consent change was made.
+const (
+	ConsentSourceRegister = "register"
+	ConsentSourceLogin    = "login"
+	ConsentSourceSettings = "settings"
+)
+
+// UserConsent is one entry of the consent audit trail: the user granted or
+// withdrew consent to Channel from Source.
+type UserConsent struct {
+	ID        int64     `db:"id" json:"id"`
+	UserID    int64     `db:"user_id" json:"user_id"`
+	Channel   string    `db:"channel" json:"channel"`
+	Granted   bool      `db:"granted" json:"granted"`
+	Source    string    `db:"source" json:"source"`
+	Platform  string    `db:"platform" json:"platform"`
+	IP        string    `db:"ip" json:"ip"`
+	Agent     string    `db:"agent" json:"agent"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
 }
 
 type SMSCResponse struct {
-	ID     int    `json:"id"`
-	Count  int    `json:"count"`
-	Cost   string `json:"cost"`
-	Status int    `json:"status"`
-	Error  string `json:"error"`
+	ID        int    `json:"id"`
+	Count     int    `json:"cnt"`
+	Cost      string `json:"cost"`
+	Status    int    `json:"status"`
+	Error     string `json:"error"`
+	ErrorCode int    `json:"error_code"`
+
+	// Code is the flash call code, the last digits of the calling number.
+	Code string `json:"code"`
+}
+
+// SMSCStatusResponse is returned by SMSC's status.php for a single message.
+type SMSCStatusResponse struct {
+	Status        int    `json:"status"`
+	LastDate      string `json:"last_date"`
+	LastTimestamp int64  `json:"last_timestamp"`
+	Err           int    `json:"err"`
+	Error         string `json:"error"`
+	ErrorCode     int    `json:"error_code"`
+}
+
+const (
+	SMSStatusPending   = "pending"
+	SMSStatusSent      = "sent"
+	SMSStatusDelivered = "delivered"
+	SMSStatusFailed    = "failed"
+)
+
+// SMSMessage records one outgoing SMS. Status moves from pending to sent once
+// a provider accepted it, and to delivered or failed once the provider
+// reports back; ProviderStatus keeps the provider's own status text.
+type SMSMessage struct {
+	ID                int64        `db:"id" json:"id"`
+	Phone             string       `db:"phone" json:"phone"`
+	Provider          string       `db:"provider" json:"provider"`
+	ProviderMessageID string       `db:"provider_message_id" json:"provider_message_id"`
+	Status            string       `db:"status" json:"status"`
+	ProviderStatus    string       `db:"provider_status" json:"provider_status"`
+	Parts             int          `db:"parts" json:"parts"`
+	Cost              string       `db:"cost" json:"cost"`
+	Error             string       `db:"error" json:"error"`
+	SentAt            sql.NullTime `db:"sent_at" json:"sent_at,omitempty"`
+	DeliveredAt       sql.NullTime `db:"delivered_at" json:"delivered_at,omitempty"`
+	CheckedAt         sql.NullTime `db:"checked_at" json:"checked_at,omitempty"`
+	CreatedAt         time.Time    `db:"created_at" json:"created_at"`
+	UpdatedAt         time.Time    `db:"updated_at" json:"updated_at"`
+}
+
+const (
+	MindboxOperationRegister = "register"
+	MindboxOperationLogin    = "login"
+	// MindboxOperationSubscriptions sends the user's current consent.
+	MindboxOperationSubscriptions = "subscriptions"
+
+	MindboxOutboxPending = "pending"
+	MindboxOutboxSent    = "sent"
+	MindboxOutboxDead    = "dead"
+)
+
+// MindboxOutboxEvent is a Mindbox operation waiting to be sent for a user.
+// It stays pending, retried at NextAttemptAt, until it is sent or has failed
+// too often and is dead. Payload holds the request context as JSON.
+type MindboxOutboxEvent struct {
+	ID            int64        `db:"id" json:"id"`
+	UserID        int64        `db:"user_id" json:"user_id"`
+	Operation     string       `db:"operation" json:"operation"`
+	Payload       string       `db:"payload" json:"payload"`
+	Status        string       `db:"status" json:"status"`
+	Attempts      int          `db:"attempts" json:"attempts"`
+	LastError     string       `db:"last_error" json:"last_error"`
+	NextAttemptAt time.Time    `db:"next_attempt_at" json:"next_attempt_at"`
+	SentAt        sql.NullTime `db:"sent_at" json:"sent_at,omitempty"`
+	CreatedAt     time.Time    `db:"created_at" json:"created_at"`
+	UpdatedAt     time.Time    `db:"updated_at" json:"updated_at"`
 }
@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"sso/internal/models"
+	"sso/pkg/apperror"
+
+	"github.com/antibomberman/qb"
+)
+
+type ClientRepository interface {
+	FindByClientID(clientID string) (*models.Client, error)
+	List() ([]models.Client, error)
+	Create(client *models.Client) (*models.Client, error)
+	Update(client *models.Client) error
+	UpdateSecret(clientID, secretHash string) error
+	Deactivate(clientID string) error
+}
+
+type clientRepository struct {
+	qb qb.QueryBuilderInterface
+}
+
+func NewClientRepository(db *sql.DB) ClientRepository {
+	return &clientRepository{
+		qb: qb.New("mysql", db),
+	}
+}
+
+func (r *clientRepository) FindByClientID(clientID string) (*models.Client, error) {
+	var client models.Client
+
+	found, err := r.qb.From("clients").
+		Where("client_id = ?", clientID).
+		Limit(1).
+		First(&client)
+
+	if err != nil {
+		return nil, apperror.Database(err, "query error")
+	}
+
+	if !found {
+		return nil, nil
+	}
+
+	return &client, nil
+}
+
+func (r *clientRepository) List() ([]models.Client, error) {
+	var clients []models.Client
+
+	_, err := r.qb.From("clients").
+		OrderBy("id", "ASC").
+		Get(&clients)
+
+	if err != nil {
+		return nil, apperror.Database(err, "query error")
+	}
+
+	return clients, nil
+}
+
+func (r *clientRepository) Create(client *models.Client) (*models.Client, error) {
+	now := time.Now()
+
+	id, err := r.qb.From("clients").CreateMap(map[string]any{
+		"client_id":          client.ClientID,
+		"client_secret_hash": client.ClientSecretHash,
+		"name":               client.Name,
+		"redirect_uris":      client.RedirectURIs,
+		"grant_types":        client.GrantTypes,
+		"scopes":             client.Scopes,
+		"is_active":          true,
+		"created_at":         now,
+		"updated_at":         now,
+	})
+
+	if err != nil {
+		return nil, apperror.Database(err, "failed to create client")
+	}
+
+	created := *client
+	created.ID = id.(int64)
+	created.IsActive = true
+	created.CreatedAt = now
+	created.UpdatedAt = now
+	return &created, nil
+}
+
+func (r *clientRepository) Update(client *models.Client) error {
+	err := r.qb.From("clients").
+		Where("client_id = ?", client.ClientID).
+		UpdateMap(map[string]any{
+			"name":          client.Name,
+			"redirect_uris": client.RedirectURIs,
+			"grant_types":   client.GrantTypes,
+			"scopes":        client.Scopes,
+			"is_active":     client.IsActive,
+			"updated_at":    time.Now(),
+		})
+
+	if err != nil {
+		return apperror.Database(err, "failed to update client")
+	}
+	return nil
+}
+
+func (r *clientRepository) UpdateSecret(clientID, secretHash string) error {
+	err := r.qb.From("clients").
+		Where("client_id = ?", clientID).
+		UpdateMap(map[string]any{
+			"client_secret_hash": secretHash,
+			"updated_at":         time.Now(),
+		})
+
+	if err != nil {
+		return apperror.Database(err, "failed to update client secret")
+	}
+	return nil
+}
+
+func (r *clientRepository) Deactivate(clientID string) error {
+	err := r.qb.From("clients").
+		Where("client_id = ?", clientID).
+		UpdateMap(map[string]any{
+			"is_active":  false,
+			"updated_at": time.Now(),
+		})
+
+	if err != nil {
+		return apperror.Database(err, "failed to deactivate client")
+	}
+	return nil
+}
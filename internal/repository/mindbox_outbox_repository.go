@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"sso/internal/models"
+	"sso/pkg/apperror"
+
+	"github.com/antibomberman/qb"
+)
+
+type MindboxOutboxRepository interface {
+	Enqueue(userID int64, operation, payload string) (int64, error)
+	FindDue(now time.Time, limit int) ([]models.MindboxOutboxEvent, error)
+	Claim(id int64, now, leaseUntil time.Time) (bool, error)
+	MarkSent(id int64) error
+	MarkFailed(id int64, attempts int, reason string, nextAttemptAt time.Time) error
+	MarkDead(id int64, attempts int, reason string) error
+	FindByID(id int64) (*models.MindboxOutboxEvent, error)
+	FindByStatus(status string, userID int64, limit int) ([]models.MindboxOutboxEvent, error)
+	Replay(id int64) (bool, error)
+}
+
+type mindboxOutboxRepository struct {
+	qb qb.QueryBuilderInterface
+}
+
+func NewMindboxOutboxRepository(db *sql.DB) MindboxOutboxRepository {
+	return &mindboxOutboxRepository{
+		qb: qb.New("mysql", db),
+	}
+}
+
+// mindboxOutboxRow is shared with the user repository, which enqueues the
+// registration of a new user in the transaction that creates it.
+func mindboxOutboxRow(userID int64, operation, payload string, now time.Time) map[string]any {
+	return map[string]any{
+		"user_id":         userID,
+		"operation":       operation,
+		"payload":         payload,
+		"status":          models.MindboxOutboxPending,
+		"next_attempt_at": now,
+		"created_at":      now,
+		"updated_at":      now,
+	}
+}
+
+func (r *mindboxOutboxRepository) Enqueue(userID int64, operation, payload string) (int64, error) {
+	id, err := r.qb.From("mindbox_outbox").CreateMap(mindboxOutboxRow(userID, operation, payload, time.Now()))
+	if err != nil {
+		return 0, apperror.Database(err, "failed to enqueue mindbox event")
+	}
+	return id.(int64), nil
+}
+
+// FindDue returns pending events whose next attempt is due, oldest first.
+func (r *mindboxOutboxRepository) FindDue(now time.Time, limit int) ([]models.MindboxOutboxEvent, error) {
+	var events []models.MindboxOutboxEvent
+
+	_, err := r.qb.From("mindbox_outbox").
+		Where("status = ?", models.MindboxOutboxPending).
+		Where("next_attempt_at <= ?", now).
+		OrderBy("next_attempt_at", "ASC").
+		OrderBy("id", "ASC").
+		Limit(limit).
+		Get(&events)
+
+	if err != nil {
+		return nil, apperror.Database(err, "query error")
+	}
+
+	return events, nil
+}
+
+// Claim pushes the next attempt of a due event to leaseUntil so that no other
+// instance picks it up meanwhile. It reports false when another one was first.
+// An event whose worker dies is retried once the lease runs out.
+func (r *mindboxOutboxRepository) Claim(id int64, now, leaseUntil time.Time) (bool, error) {
+	res, err := r.qb.GetDB().Exec(
+		"UPDATE mindbox_outbox SET next_attempt_at = ?, updated_at = ? WHERE id = ? AND status = ? AND next_attempt_at <= ?",
+		leaseUntil, now, id, models.MindboxOutboxPending, now,
+	)
+	if err != nil {
+		return false, apperror.Database(err, "failed to claim mindbox event")
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, apperror.Database(err, "failed to claim mindbox event")
+	}
+	return affected > 0, nil
+}
+
+func (r *mindboxOutboxRepository) MarkSent(id int64) error {
+	now := time.Now()
+
+	err := r.qb.From("mindbox_outbox").
+		Where("id = ?", id).
+		UpdateMap(map[string]any{
+			"status":     models.MindboxOutboxSent,
+			"last_error": "",
+			"sent_at":    now,
+			"updated_at": now,
+		})
+
+	if err != nil {
+		return apperror.Database(err, "failed to update mindbox event")
+	}
+	return nil
+}
+
+func (r *mindboxOutboxRepository) MarkFailed(id int64, attempts int, reason string, nextAttemptAt time.Time) error {
+	err := r.qb.From("mindbox_outbox").
+		Where("id = ?", id).
+		UpdateMap(map[string]any{
+			"attempts":        attempts,
+			"last_error":      truncate(reason, 512),
+			"next_attempt_at": nextAttemptAt,
+			"updated_at":      time.Now(),
+		})
+
+	if err != nil {
+		return apperror.Database(err, "failed to update mindbox event")
+	}
+	return nil
+}
+
+func (r *mindboxOutboxRepository) MarkDead(id int64, attempts int, reason string) error {
+	err := r.qb.From("mindbox_outbox").
+		Where("id = ?", id).
+		UpdateMap(map[string]any{
+			"status":     models.MindboxOutboxDead,
+			"attempts":   attempts,
+			"last_error": truncate(reason, 512),
+			"updated_at": time.Now(),
+		})
+
+	if err != nil {
+		return apperror.Database(err, "failed to update mindbox event")
+	}
+	return nil
+}
+
+func (r *mindboxOutboxRepository) FindByID(id int64) (*models.MindboxOutboxEvent, error) {
+	var event models.MindboxOutboxEvent
+
+	found, err := r.qb.From("mindbox_outbox").
+		Where("id = ?", id).
+		Limit(1).
+		First(&event)
+
+	if err != nil {
+		return nil, apperror.Database(err, "query error")
+	}
+
+	if !found {
+		return nil, nil
+	}
+
+	return &event, nil
+}
+
+// FindByStatus lists events with status, newest first. A zero userID lists
+// them for every user.
+func (r *mindboxOutboxRepository) FindByStatus(status string, userID int64, limit int) ([]models.MindboxOutboxEvent, error) {
+	var events []models.MindboxOutboxEvent
+
+	query := r.qb.From("mindbox_outbox").
+		Where("status = ?", status)
+	if userID != 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	_, err := query.
+		OrderBy("id", "DESC").
+		Limit(limit).
+		Get(&events)
+
+	if err != nil {
+		return nil, apperror.Database(err, "query error")
+	}
+
+	return events, nil
+}
+
+// Replay puts a dead event back in the queue with a fresh attempt budget. It
+// reports false when the event is not dead.
+func (r *mindboxOutboxRepository) Replay(id int64) (bool, error) {
+	now := time.Now()
+
+	res, err := r.qb.GetDB().Exec(
+		"UPDATE mindbox_outbox SET status = ?, attempts = 0, next_attempt_at = ?, updated_at = ? WHERE id = ? AND status = ?",
+		models.MindboxOutboxPending, now, now, id, models.MindboxOutboxDead,
+	)
+	if err != nil {
+		return false, apperror.Database(err, "failed to replay mindbox event")
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, apperror.Database(err, "failed to replay mindbox event")
+	}
+	return affected > 0, nil
+}
@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"sso/internal/models"
+	"sso/pkg/apperror"
+
+	"github.com/antibomberman/qb"
+)
+
+type SMSMessageRepository interface {
+	Create(phone string) (int64, error)
+	MarkSent(id int64, provider, providerMessageID, cost string, parts int) error
+	MarkFailed(id int64, provider, reason string) error
+	UpdateStatus(id int64, status, providerStatus, reason string, deliveredAt *time.Time) error
+	FindUndelivered(providers []string, since time.Time, limit int) ([]models.SMSMessage, error)
+	FindByPhone(phone string, limit int) ([]models.SMSMessage, error)
+}
+
+type smsMessageRepository struct {
+	qb qb.QueryBuilderInterface
+}
+
+func NewSMSMessageRepository(db *sql.DB) SMSMessageRepository {
+	return &smsMessageRepository{
+		qb: qb.New("mysql", db),
+	}
+}
+
+func (r *smsMessageRepository) Create(phone string) (int64, error) {
+	now := time.Now()
+
+	id, err := r.qb.From("sms_messages").CreateMap(map[string]any{
+		"phone":      phone,
+		"status":     models.SMSStatusPending,
+		"created_at": now,
+		"updated_at": now,
+	})
+
+	if err != nil {
+		return 0, apperror.Database(err, "failed to create sms message")
+	}
+	return id.(int64), nil
+}
+
+func (r *smsMessageRepository) MarkSent(id int64, provider, providerMessageID, cost string, parts int) error {
+	now := time.Now()
+
+	err := r.qb.From("sms_messages").
+		Where("id = ?", id).
+		UpdateMap(map[string]any{
+			"provider":            provider,
+			"provider_message_id": providerMessageID,
+			"status":              models.SMSStatusSent,
+			"cost":                cost,
+			"parts":               parts,
+			"error":               "",
+			"sent_at":             now,
+			"updated_at":          now,
+		})
+
+	if err != nil {
+		return apperror.Database(err, "failed to update sms message")
+	}
+	return nil
+}
+
+func (r *smsMessageRepository) MarkFailed(id int64, provider, reason string) error {
+	err := r.qb.From("sms_messages").
+		Where("id = ?", id).
+		UpdateMap(map[string]any{
+			"provider":   provider,
+			"status":     models.SMSStatusFailed,
+			"error":      truncate(reason, 512),
+			"updated_at": time.Now(),
+		})
+
+	if err != nil {
+		return apperror.Database(err, "failed to update sms message")
+	}
+	return nil
+}
+
+// UpdateStatus stores the outcome of a status check. deliveredAt is only set
+// once the message has been delivered.
+func (r *smsMessageRepository) UpdateStatus(id int64, status, providerStatus, reason string, deliveredAt *time.Time) error {
+	now := time.Now()
+
+	values := map[string]any{
+		"status":          status,
+		"provider_status": providerStatus,
+		"error":           truncate(reason, 512),
+		"checked_at":      now,
+		"updated_at":      now,
+	}
+	if deliveredAt != nil {
+		values["delivered_at"] = *deliveredAt
+	}
+
+	err := r.qb.From("sms_messages").
+		Where("id = ?", id).
+		UpdateMap(values)
+
+	if err != nil {
+		return apperror.Database(err, "failed to update sms message")
+	}
+	return nil
+}
+
+// FindUndelivered returns messages one of providers accepted after since but
+// has not yet reported as delivered or failed, least recently checked first.
+func (r *smsMessageRepository) FindUndelivered(providers []string, since time.Time, limit int) ([]models.SMSMessage, error) {
+	var messages []models.SMSMessage
+
+	names := make([]any, len(providers))
+	for i, provider := range providers {
+		names[i] = provider
+	}
+
+	_, err := r.qb.From("sms_messages").
+		Where("status = ?", models.SMSStatusSent).
+		WhereIn("provider", names...).
+		Where("created_at > ?", since).
+		OrderBy("checked_at", "ASC").
+		OrderBy("id", "ASC").
+		Limit(limit).
+		Get(&messages)
+
+	if err != nil {
+		return nil, apperror.Database(err, "query error")
+	}
+
+	return messages, nil
+}
+
+func (r *smsMessageRepository) FindByPhone(phone string, limit int) ([]models.SMSMessage, error) {
+	var messages []models.SMSMessage
+
+	_, err := r.qb.From("sms_messages").
+		Where("phone = ?", phone).
+		OrderBy("id", "DESC").
+		Limit(limit).
+		Get(&messages)
+
+	if err != nil {
+		return nil, apperror.Database(err, "query error")
+	}
+
+	return messages, nil
+}
+
+// truncate cuts s to at most max characters without splitting a rune.
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max])
+}
@@ -2,9 +2,10 @@ package repository
 
 import (
 	"database/sql"
-	"fmt"
+	"strings"
 
 	"sso/internal/models"
+	"sso/pkg/apperror"
 
 	"github.com/antibomberman/qb"
 )
@@ -27,13 +28,14 @@ func NewTestAccountRepository(db *sql.DB) TestAccountRepository {
 func (r *testAccountRepository) FindByPhone(phone string) (*models.TestAccount, error) {
 	var account models.TestAccount
 
+	// Rows not yet migrated to E.164 hold the number without the "+".
 	found, err := r.qb.From("test_accounts").
-		Where("phone = ?", phone).
+		WhereIn("phone", phone, strings.TrimPrefix(phone, "+")).
 		Limit(1).
 		First(&account)
 
 	if err != nil {
-		return nil, fmt.Errorf("query error: %w", err)
+		return nil, apperror.Database(err, "query error")
 	}
 
 	if !found {
@@ -51,7 +53,7 @@ func (r *testAccountRepository) UpdateCode(phone, code string) error {
 		})
 
 	if err != nil {
-		return fmt.Errorf("failed to update code: %w", err)
+		return apperror.Database(err, "failed to update code")
 	}
 
 	return nil
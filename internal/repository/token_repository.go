@@ -2,19 +2,28 @@ package repository
 
 import (
 	"database/sql"
-	"fmt"
 	"time"
 
 	"sso/internal/models"
+	"sso/pkg/apperror"
 
 	"github.com/antibomberman/qb"
 )
 
 type TokenRepository interface {
-	Create(userID int64, token, agent, ip string, expireAt time.Time) error
+	Create(userID int64, token, familyID, clientID, platform, deviceUUID, agent, ip string, expireAt time.Time) error
 	FindByToken(token string) (*models.UserAccessToken, error)
+	FindAnyByToken(token string) (*models.UserAccessToken, error)
+	FindActiveByUser(userID int64) ([]models.UserAccessToken, error)
+	UpdateSessionToken(familyID, token, agent, ip string, expireAt time.Time) error
 	Deactivate(token string) error
+	DeactivateFamily(familyID string) error
 	DeactivateAllUserTokens(userID int64) error
+
+	CreateRefreshToken(userID int64, tokenHash, familyID, clientID, scope, agent, ip string, expireAt time.Time) error
+	FindRefreshToken(tokenHash string) (*models.UserRefreshToken, error)
+	RotateRefreshToken(current *models.UserRefreshToken, nextHash, agent, ip string, expireAt time.Time) (bool, error)
+	RevokeRefreshFamily(familyID string) error
 }
 
 type tokenRepository struct {
@@ -27,21 +36,28 @@ func NewTokenRepository(db *sql.DB) TokenRepository {
 	}
 }
 
-func (r *tokenRepository) Create(userID int64, token, agent, ip string, expireAt time.Time) error {
-	if err := r.DeactivateAllUserTokens(userID); err != nil {
-		return fmt.Errorf("failed to deactivate existing tokens: %w", err)
-	}
+// Create records a new session. Other sessions of the user stay active.
+func (r *tokenRepository) Create(userID int64, token, familyID, clientID, platform, deviceUUID, agent, ip string, expireAt time.Time) error {
+	now := time.Now()
 
 	_, err := r.qb.From("user_access_tokens").CreateMap(map[string]any{
-		"user_id":   userID,
-		"token":     token,
-		"agent":     agent,
-		"ip":        ip,
-		"expire_at": expireAt,
+		"user_id":      userID,
+		"token":        token,
+		"family_id":    familyID,
+		"client_id":    clientID,
+		"agent":        agent,
+		"ip":           ip,
+		"platform":     platform,
+		"device_uuid":  deviceUUID,
+		"expire_at":    expireAt,
+		"login_at":     now,
+		"last_seen_at": now,
+		"created_at":   now,
+		"updated_at":   now,
 	})
 
 	if err != nil {
-		return fmt.Errorf("failed to create token: %w", err)
+		return apperror.Database(err, "failed to create token")
 	}
 
 	return nil
@@ -57,7 +73,28 @@ func (r *tokenRepository) FindByToken(token string) (*models.UserAccessToken, er
 		First(&accessToken)
 
 	if err != nil {
-		return nil, fmt.Errorf("query error: %w", err)
+		return nil, apperror.Database(err, "query error")
+	}
+
+	if !found {
+		return nil, nil
+	}
+
+	return &accessToken, nil
+}
+
+// FindAnyByToken also returns expired and deactivated tokens, so callers can
+// tell a revoked token apart from one that was never recorded.
+func (r *tokenRepository) FindAnyByToken(token string) (*models.UserAccessToken, error) {
+	var accessToken models.UserAccessToken
+
+	found, err := r.qb.From("user_access_tokens").
+		Where("token = ?", token).
+		Limit(1).
+		First(&accessToken)
+
+	if err != nil {
+		return nil, apperror.Database(err, "query error")
 	}
 
 	if !found {
@@ -67,15 +104,72 @@ func (r *tokenRepository) FindByToken(token string) (*models.UserAccessToken, er
 	return &accessToken, nil
 }
 
+// FindActiveByUser returns the user's active sessions, oldest first.
+func (r *tokenRepository) FindActiveByUser(userID int64) ([]models.UserAccessToken, error) {
+	var sessions []models.UserAccessToken
+
+	_, err := r.qb.From("user_access_tokens").
+		Where("user_id = ?", userID).
+		Where("expire_at > NOW()").
+		OrderBy("login_at", "ASC").
+		OrderBy("id", "ASC").
+		Get(&sessions)
+
+	if err != nil {
+		return nil, apperror.Database(err, "query error")
+	}
+
+	return sessions, nil
+}
+
+// UpdateSessionToken moves an active session on to the access token issued by
+// a refresh and extends it to the new refresh token expiry.
+func (r *tokenRepository) UpdateSessionToken(familyID, token, agent, ip string, expireAt time.Time) error {
+	now := time.Now()
+
+	err := r.qb.From("user_access_tokens").
+		Where("family_id = ?", familyID).
+		Where("expire_at > ?", now).
+		UpdateMap(map[string]any{
+			"token":        token,
+			"agent":        agent,
+			"ip":           ip,
+			"expire_at":    expireAt,
+			"last_seen_at": now,
+			"updated_at":   now,
+		})
+
+	if err != nil {
+		return apperror.Database(err, "failed to update session")
+	}
+	return nil
+}
+
 func (r *tokenRepository) Deactivate(token string) error {
 	err := r.qb.From("user_access_tokens").
 		Where("token = ?", token).
 		UpdateMap(map[string]any{
-			"expire_at": "NOW()",
+			"expire_at": time.Now(),
+		})
+
+	if err != nil {
+		return apperror.Database(err, "failed to deactivate token")
+	}
+	return nil
+}
+
+func (r *tokenRepository) DeactivateFamily(familyID string) error {
+	now := time.Now()
+
+	err := r.qb.From("user_access_tokens").
+		Where("family_id = ?", familyID).
+		Where("expire_at > ?", now).
+		UpdateMap(map[string]any{
+			"expire_at": now,
 		})
 
 	if err != nil {
-		return fmt.Errorf("failed to deactivate token: %w", err)
+		return apperror.Database(err, "failed to deactivate session")
 	}
 	return nil
 }
@@ -84,11 +178,115 @@ func (r *tokenRepository) DeactivateAllUserTokens(userID int64) error {
 	err := r.qb.From("user_access_tokens").
 		Where("user_id = ?", userID).
 		UpdateMap(map[string]any{
-			"expire_at": "NOW()",
+			"expire_at": time.Now(),
+		})
+
+	if err != nil {
+		return apperror.Database(err, "failed to deactivate user tokens")
+	}
+	return nil
+}
+
+func (r *tokenRepository) CreateRefreshToken(userID int64, tokenHash, familyID, clientID, scope, agent, ip string, expireAt time.Time) error {
+	_, err := r.qb.From("user_refresh_tokens").CreateMap(map[string]any{
+		"user_id":    userID,
+		"token_hash": tokenHash,
+		"family_id":  familyID,
+		"client_id":  clientID,
+		"scope":      scope,
+		"agent":      agent,
+		"ip":         ip,
+		"expire_at":  expireAt,
+		"created_at": time.Now(),
+	})
+
+	if err != nil {
+		return apperror.Database(err, "failed to create refresh token")
+	}
+
+	return nil
+}
+
+// FindRefreshToken returns the token regardless of its state so that the caller
+// can tell a reused (already rotated) token apart from an unknown one.
+func (r *tokenRepository) FindRefreshToken(tokenHash string) (*models.UserRefreshToken, error) {
+	var refreshToken models.UserRefreshToken
+
+	found, err := r.qb.From("user_refresh_tokens").
+		Where("token_hash = ?", tokenHash).
+		Limit(1).
+		First(&refreshToken)
+
+	if err != nil {
+		return nil, apperror.Database(err, "query error")
+	}
+
+	if !found {
+		return nil, nil
+	}
+
+	return &refreshToken, nil
+}
+
+// RotateRefreshToken marks current as used and stores its successor in the same
+// family. It reports false when current was rotated or revoked concurrently.
+func (r *tokenRepository) RotateRefreshToken(current *models.UserRefreshToken, nextHash, agent, ip string, expireAt time.Time) (bool, error) {
+	rotated := false
+
+	err := r.qb.Transaction(func(tx *qb.Transaction) error {
+		now := time.Now()
+		res, err := tx.Tx.Exec(
+			"UPDATE user_refresh_tokens SET rotated_at = ? WHERE id = ? AND rotated_at IS NULL AND revoked_at IS NULL",
+			now, current.ID,
+		)
+		if err != nil {
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return nil
+		}
+
+		_, err = tx.From("user_refresh_tokens").CreateMap(map[string]any{
+			"user_id":    current.UserID,
+			"token_hash": nextHash,
+			"family_id":  current.FamilyID,
+			"client_id":  current.ClientID,
+			"scope":      current.Scope,
+			"agent":      agent,
+			"ip":         ip,
+			"expire_at":  expireAt,
+			"created_at": now,
+		})
+		if err != nil {
+			return err
+		}
+
+		rotated = true
+		return nil
+	})
+
+	if err != nil {
+		return false, apperror.Database(err, "failed to rotate refresh token")
+	}
+
+	return rotated, nil
+}
+
+func (r *tokenRepository) RevokeRefreshFamily(familyID string) error {
+	err := r.qb.From("user_refresh_tokens").
+		Where("family_id = ?", familyID).
+		WhereNull("revoked_at").
+		UpdateMap(map[string]any{
+			"revoked_at": time.Now(),
 		})
 
 	if err != nil {
-		return fmt.Errorf("failed to deactivate user tokens: %w", err)
+		return apperror.Database(err, "failed to revoke refresh token family")
 	}
 	return nil
 }
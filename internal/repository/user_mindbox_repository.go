@@ -2,9 +2,10 @@ package repository
 
 import (
 	"database/sql"
-	"fmt"
+	"time"
 
 	"sso/internal/models"
+	"sso/pkg/apperror"
 
 	"github.com/antibomberman/qb"
 )
@@ -12,6 +13,8 @@ import (
 type UserMindBoxRepository interface {
 	FindByUserID(userID int64) (*models.UserMindBox, error)
 	Create(userID int64) (*models.UserMindBox, error)
+	UpdateMindboxCustomer(userID int64, mindboxID, status string) error
+	UpdateConsent(userID int64, changes []models.UserConsent) error
 }
 
 type userMindBoxRepository struct {
@@ -33,7 +36,7 @@ func (r *userMindBoxRepository) FindByUserID(userID int64) (*models.UserMindBox,
 		First(&userMindBox)
 
 	if err != nil {
-		return nil, fmt.Errorf("query error: %w", err)
+		return nil, apperror.Database(err, "query error")
 	}
 
 	if !found {
@@ -43,16 +46,43 @@ func (r *userMindBoxRepository) FindByUserID(userID int64) (*models.UserMindBox,
 	return &userMindBox, nil
 }
 
-func (r *userMindBoxRepository) Create(userID int64) (*models.UserMindBox, error) {
-	id, err := r.qb.From("user_mind_box").CreateMap(map[string]any{
+// userMindBoxRow is the user_mind_box record of a new user with its first
+// consent applied. It is shared with the user repository, which creates the
+// record along with the user.
+func userMindBoxRow(userID int64, consent []models.UserConsent) map[string]any {
+	row := map[string]any{
 		"user_id":                  userID,
 		"consent_to_mailings":      false,
 		"loyalty_program_enrolled": false,
 		"is_phone_confirm":         false,
-	})
+	}
+	for _, c := range consent {
+		row["consent_"+c.Channel] = c.Granted
+		if c.Granted {
+			row["consent_to_mailings"] = true
+		}
+	}
+	return row
+}
+
+func userConsentRow(userID int64, c models.UserConsent, now time.Time) map[string]any {
+	return map[string]any{
+		"user_id":    userID,
+		"channel":    c.Channel,
+		"granted":    c.Granted,
+		"source":     c.Source,
+		"platform":   truncate(c.Platform, 16),
+		"ip":         truncate(c.IP, 64),
+		"agent":      truncate(c.Agent, 255),
+		"created_at": now,
+	}
+}
+
+func (r *userMindBoxRepository) Create(userID int64) (*models.UserMindBox, error) {
+	id, err := r.qb.From("user_mind_box").CreateMap(userMindBoxRow(userID, nil))
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to create record in user_mind_box: %w", err)
+		return nil, apperror.Database(err, "failed to create record in user_mind_box")
 	}
 
 	return &models.UserMindBox{
@@ -63,3 +93,67 @@ func (r *userMindBoxRepository) Create(userID int64) (*models.UserMindBox, error
 		IsPhoneConfirm:         sql.NullBool{Bool: false, Valid: true},
 	}, nil
 }
+
+// UpdateMindboxCustomer records how Mindbox processed the user's customer. An
+// empty mindboxID keeps the id stored before.
+func (r *userMindBoxRepository) UpdateMindboxCustomer(userID int64, mindboxID, status string) error {
+	data := map[string]any{
+		"mind_box_status":    status,
+		"mind_box_synced_at": time.Now(),
+	}
+	if mindboxID != "" {
+		data["mind_box_user_id"] = mindboxID
+	}
+
+	err := r.qb.From("user_mind_box").
+		Where("user_id = ?", userID).
+		UpdateMap(data)
+
+	if err != nil {
+		return apperror.Database(err, "failed to update record in user_mind_box")
+	}
+	return nil
+}
+
+// UpdateConsent stores the changed consent of a user that has a user_mind_box
+// record and adds the changes to the audit trail.
+func (r *userMindBoxRepository) UpdateConsent(userID int64, changes []models.UserConsent) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	data := make(map[string]any, len(changes))
+	for _, c := range changes {
+		data["consent_"+c.Channel] = c.Granted
+	}
+
+	err := r.qb.Transaction(func(tx *qb.Transaction) error {
+		err := tx.From("user_mind_box").
+			Where("user_id = ?", userID).
+			UpdateMap(data)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Tx.Exec(
+			"UPDATE user_mind_box SET consent_to_mailings = (COALESCE(consent_email, 0) + COALESCE(consent_sms, 0) + COALESCE(consent_webpush, 0) > 0) WHERE user_id = ?",
+			userID,
+		)
+		if err != nil {
+			return err
+		}
+
+		for _, c := range changes {
+			if _, err := tx.From("user_consent_log").CreateMap(userConsentRow(userID, c, now)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		return apperror.Database(err, "failed to update consent")
+	}
+	return nil
+}
@@ -4,16 +4,19 @@ import (
 	"database/sql"
 	"fmt"
 	"math/rand"
+	"strings"
 	"time"
 
 	"sso/internal/models"
+	"sso/pkg/apperror"
 
 	"github.com/antibomberman/qb"
 )
 
 type UserRepository interface {
 	FindByPhone(phone string) (*models.User, error)
-	Create(phone string) (*models.User, error)
+	FindByID(id int64) (*models.User, error)
+	Create(phone string, consent []models.UserConsent, mindboxPayload string) (*models.User, error)
 }
 
 type userRepository struct {
@@ -29,14 +32,15 @@ func NewUserRepository(db *sql.DB) UserRepository {
 func (r *userRepository) FindByPhone(phone string) (*models.User, error) {
 	var user models.User
 
+	// Rows not yet migrated to E.164 hold the number without the "+".
 	found, err := r.qb.From("user").
-		Where("phone = ?", phone).
+		WhereIn("phone", phone, strings.TrimPrefix(phone, "+")).
 		WhereNull("deleted_at").
 		Limit(1).
 		First(&user)
 
 	if err != nil {
-		return nil, fmt.Errorf("query error: %w", err)
+		return nil, apperror.Database(err, "query error")
 	}
 
 	if !found {
@@ -46,29 +50,72 @@ func (r *userRepository) FindByPhone(phone string) (*models.User, error) {
 	return &user, nil
 }
 
-func (r *userRepository) Create(phone string) (*models.User, error) {
+func (r *userRepository) FindByID(id int64) (*models.User, error) {
+	var user models.User
+
+	found, err := r.qb.From("user").
+		Where("id = ?", id).
+		WhereNull("deleted_at").
+		Limit(1).
+		First(&user)
+
+	if err != nil {
+		return nil, apperror.Database(err, "query error")
+	}
+
+	if !found {
+		return nil, nil
+	}
+
+	return &user, nil
+}
+
+// Create inserts a new user and, in the same transaction, its user_mind_box
+// record with the consent it registered with and its queued Mindbox
+// registration with mindboxPayload, so that none is stored without the others.
+func (r *userRepository) Create(phone string, consent []models.UserConsent, mindboxPayload string) (*models.User, error) {
 	now := time.Now().Unix()
 	username := fmt.Sprintf("user_%d", now)
 	authKey := GenerateAuthKey()
 
-	id, err := r.qb.From("user").CreateMap(map[string]any{
-		"username":      username,
-		"auth_key":      authKey,
-		"lang":          "ru",
-		"password_hash": "",
-		"status":        10,
-		"created_at":    now,
-		"updated_at":    now,
-		"phone":         phone,
-		"is_guest":      false,
+	var userID int64
+	err := r.qb.Transaction(func(tx *qb.Transaction) error {
+		id, err := tx.From("user").CreateMap(map[string]any{
+			"username":      username,
+			"auth_key":      authKey,
+			"lang":          "ru",
+			"password_hash": "",
+			"status":        10,
+			"created_at":    now,
+			"updated_at":    now,
+			"phone":         phone,
+			"is_guest":      false,
+		})
+		if err != nil {
+			return err
+		}
+		userID = id.(int64)
+
+		if _, err := tx.From("user_mind_box").CreateMap(userMindBoxRow(userID, consent)); err != nil {
+			return err
+		}
+		for _, c := range consent {
+			if _, err := tx.From("user_consent_log").CreateMap(userConsentRow(userID, c, time.Unix(now, 0))); err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.From("mindbox_outbox").CreateMap(
+			mindboxOutboxRow(userID, models.MindboxOperationRegister, mindboxPayload, time.Unix(now, 0)))
+		return err
 	})
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to create user: %w", err)
+		return nil, apperror.Database(err, "failed to create user")
 	}
 
 	return &models.User{
-		ID:        id.(int64),
+		ID:        userID,
 		Username:  username,
 		AuthKey:   authKey,
 		Lang:      "ru",
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"sso/internal/config"
+	"sso/internal/logger"
+)
+
+type CaptchaVerifier interface {
+	// Configured reports whether there is an endpoint to verify tokens with.
+	Configured() bool
+	Verify(token, ip string) (bool, error)
+}
+
+type captchaVerifier struct {
+	verifyURL string
+	secret    string
+	client    *http.Client
+	logger    *logger.Logger
+}
+
+func NewCaptchaVerifier(cfg config.CaptchaConfig, logger *logger.Logger) CaptchaVerifier {
+	return &captchaVerifier{
+		verifyURL: cfg.VerifyURL,
+		secret:    cfg.Secret,
+		client:    &http.Client{Timeout: cfg.Timeout},
+		logger:    logger,
+	}
+}
+
+func (v *captchaVerifier) Configured() bool {
+	return v.verifyURL != ""
+}
+
+// Verify checks token with the siteverify endpoint. The request and response
+// format is shared by reCAPTCHA, hCaptcha and Cloudflare Turnstile.
+func (v *captchaVerifier) Verify(token, ip string) (bool, error) {
+	if v.verifyURL == "" {
+		v.logger.Warn("Captcha challenge requested but CAPTCHA_VERIFY_URL is not set")
+		return false, nil
+	}
+
+	params := url.Values{}
+	params.Set("secret", v.secret)
+	params.Set("response", token)
+	if ip != "" {
+		params.Set("remoteip", ip)
+	}
+
+	resp, err := v.client.PostForm(v.verifyURL, params)
+	if err != nil {
+		return false, fmt.Errorf("failed to verify captcha: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("captcha verification returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Success    bool     `json:"success"`
+		ErrorCodes []string `json:"error-codes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, fmt.Errorf("failed to parse captcha response: %w", err)
+	}
+	if !result.Success {
+		v.logger.Info("Captcha rejected", "error_codes", result.ErrorCodes)
+	}
+	return result.Success, nil
+}
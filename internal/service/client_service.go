@@ -0,0 +1,330 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"sso/internal/logger"
+	"sso/internal/models"
+	"sso/internal/repository"
+	"sso/pkg/apperror"
+	"sso/pkg/utils"
+)
+
+var supportedGrantTypes = []string{GrantAuthorizationCode, GrantRefreshToken}
+
+// originsRefreshInterval bounds how long a client registered through another
+// instance waits before its origins pass CORS here.
+const originsRefreshInterval = time.Minute
+
+type ClientInput struct {
+	ClientID     string   `json:"client_id"`
+	Name         string   `json:"name"`
+	RedirectURIs []string `json:"redirect_uris"`
+	GrantTypes   []string `json:"grant_types"`
+	Scopes       []string `json:"scopes"`
+	Confidential bool     `json:"confidential"`
+}
+
+type ClientService interface {
+	Register(input ClientInput) (*models.Client, string, error)
+	Update(clientID string, input ClientInput) (*models.Client, error)
+	RotateSecret(clientID string) (string, error)
+	Deactivate(clientID string) error
+	Get(clientID string) (*models.Client, error)
+	List() ([]models.Client, error)
+	Authenticate(clientID, secret string) (*models.Client, error)
+	IsOriginAllowed(origin string) bool
+}
+
+type clientService struct {
+	repo   repository.ClientRepository
+	logger *logger.Logger
+
+	originsMu     sync.Mutex
+	origins       map[string]struct{}
+	originsLoaded time.Time
+}
+
+func NewClientService(repo repository.ClientRepository, logger *logger.Logger) ClientService {
+	return &clientService{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// Register stores a new client. The plain client secret is returned only here;
+// it is empty for public clients, which authenticate with PKCE alone.
+func (s *clientService) Register(input ClientInput) (*models.Client, string, error) {
+	client, err := buildClient(input)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if client.ClientID == "" {
+		client.ClientID, err = randomHex(16)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to generate client id: %w", err)
+		}
+	} else {
+		existing, err := s.repo.FindByClientID(client.ClientID)
+		if err != nil {
+			return nil, "", err
+		}
+		if existing != nil {
+			return nil, "", apperror.ErrInvalidClient.WithMessage(fmt.Sprintf("client_id %s is already registered", client.ClientID))
+		}
+	}
+
+	var secret string
+	if input.Confidential {
+		secret, client.ClientSecretHash, err = newClientSecret()
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	created, err := s.repo.Create(client)
+	if err != nil {
+		return nil, "", err
+	}
+
+	s.invalidateOrigins()
+	s.logger.Info("OAuth client registered", "client_id", created.ClientID, "confidential", input.Confidential)
+	return created, secret, nil
+}
+
+func (s *clientService) Update(clientID string, input ClientInput) (*models.Client, error) {
+	existing, err := s.Get(clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := buildClient(input)
+	if err != nil {
+		return nil, err
+	}
+	existing.Name = client.Name
+	existing.RedirectURIs = client.RedirectURIs
+	existing.GrantTypes = client.GrantTypes
+	existing.Scopes = client.Scopes
+
+	if err := s.repo.Update(existing); err != nil {
+		return nil, err
+	}
+
+	s.invalidateOrigins()
+	return existing, nil
+}
+
+// RotateSecret replaces the client secret, turning a public client into a
+// confidential one if needed. The previous secret stops working immediately.
+func (s *clientService) RotateSecret(clientID string) (string, error) {
+	if _, err := s.Get(clientID); err != nil {
+		return "", err
+	}
+
+	secret, hash, err := newClientSecret()
+	if err != nil {
+		return "", err
+	}
+	if err := s.repo.UpdateSecret(clientID, hash); err != nil {
+		return "", err
+	}
+
+	s.logger.Info("OAuth client secret rotated", "client_id", clientID)
+	return secret, nil
+}
+
+func (s *clientService) Deactivate(clientID string) error {
+	if _, err := s.Get(clientID); err != nil {
+		return err
+	}
+	if err := s.repo.Deactivate(clientID); err != nil {
+		return err
+	}
+
+	s.invalidateOrigins()
+	s.logger.Info("OAuth client deactivated", "client_id", clientID)
+	return nil
+}
+
+func (s *clientService) Get(clientID string) (*models.Client, error) {
+	client, err := s.repo.FindByClientID(clientID)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return nil, apperror.ErrClientNotFound
+	}
+	return client, nil
+}
+
+func (s *clientService) List() ([]models.Client, error) {
+	return s.repo.List()
+}
+
+// Authenticate returns the active client for clientID. Confidential clients
+// must present their secret; public clients must not send one.
+func (s *clientService) Authenticate(clientID, secret string) (*models.Client, error) {
+	if clientID == "" {
+		return nil, apperror.ErrInvalidClient.WithMessage("client_id is required")
+	}
+
+	client, err := s.repo.FindByClientID(clientID)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil || !client.IsActive {
+		return nil, apperror.ErrInvalidClient.WithMessage("unknown client")
+	}
+
+	if client.IsConfidential() {
+		if secret == "" || !utils.CheckPasswordHash(secret, client.ClientSecretHash) {
+			return nil, apperror.ErrInvalidClient.WithMessage("client authentication failed")
+		}
+	} else if secret != "" {
+		return nil, apperror.ErrInvalidClient.WithMessage("public clients must not send a secret")
+	}
+
+	return client, nil
+}
+
+// IsOriginAllowed reports whether origin belongs to a redirect URI of an
+// active client. Origins are cached and reloaded every originsRefreshInterval.
+func (s *clientService) IsOriginAllowed(origin string) bool {
+	s.originsMu.Lock()
+	defer s.originsMu.Unlock()
+
+	if s.origins == nil || time.Since(s.originsLoaded) > originsRefreshInterval {
+		origins, err := s.loadOrigins()
+		if err != nil {
+			s.logger.Error("Failed to load client origins", "error", err)
+			if s.origins == nil {
+				return false
+			}
+		} else {
+			s.origins = origins
+		}
+		s.originsLoaded = time.Now()
+	}
+
+	_, ok := s.origins[origin]
+	return ok
+}
+
+func (s *clientService) loadOrigins() (map[string]struct{}, error) {
+	clients, err := s.repo.List()
+	if err != nil {
+		return nil, err
+	}
+
+	origins := make(map[string]struct{})
+	for _, client := range clients {
+		if !client.IsActive {
+			continue
+		}
+		for _, redirectURI := range client.RedirectURIList() {
+			u, err := url.Parse(redirectURI)
+			if err != nil || u.Host == "" {
+				continue
+			}
+			origins[u.Scheme+"://"+u.Host] = struct{}{}
+		}
+	}
+	return origins, nil
+}
+
+func (s *clientService) invalidateOrigins() {
+	s.originsMu.Lock()
+	s.originsLoaded = time.Time{}
+	s.originsMu.Unlock()
+}
+
+func buildClient(input ClientInput) (*models.Client, error) {
+	if strings.TrimSpace(input.Name) == "" {
+		return nil, apperror.ErrInvalidClient.WithMessage("name is required")
+	}
+
+	if len(input.RedirectURIs) == 0 {
+		return nil, apperror.ErrInvalidClient.WithMessage("at least one redirect_uri is required")
+	}
+	for _, redirectURI := range input.RedirectURIs {
+		if err := validateRedirectURI(redirectURI); err != nil {
+			return nil, apperror.ErrInvalidClient.WithMessage(err.Error())
+		}
+	}
+
+	grantTypes := input.GrantTypes
+	if len(grantTypes) == 0 {
+		grantTypes = supportedGrantTypes
+	}
+	for _, grantType := range grantTypes {
+		if !slices.Contains(supportedGrantTypes, grantType) {
+			return nil, apperror.ErrInvalidClient.WithMessage(fmt.Sprintf("unsupported grant type %s", grantType))
+		}
+	}
+
+	scopes := input.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{ScopeOpenID}
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(supportedScopes, scope) {
+			return nil, apperror.ErrInvalidClient.WithMessage(fmt.Sprintf("unsupported scope %s", scope))
+		}
+	}
+
+	redirectURIs, err := json.Marshal(input.RedirectURIs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode redirect uris: %w", err)
+	}
+
+	return &models.Client{
+		ClientID:     input.ClientID,
+		Name:         strings.TrimSpace(input.Name),
+		RedirectURIs: string(redirectURIs),
+		GrantTypes:   strings.Join(grantTypes, " "),
+		Scopes:       strings.Join(scopes, " "),
+	}, nil
+}
+
+// validateRedirectURI requires an absolute URI without a fragment, served over
+// https unless it points at the local machine.
+func validateRedirectURI(redirectURI string) error {
+	u, err := url.Parse(redirectURI)
+	if err != nil || !u.IsAbs() || u.Fragment != "" {
+		return fmt.Errorf("redirect_uri must be an absolute URI without a fragment")
+	}
+	if u.Scheme != "https" && !(u.Scheme == "http" && (u.Hostname() == "localhost" || u.Hostname() == "127.0.0.1")) {
+		return fmt.Errorf("redirect_uri must use https")
+	}
+	return nil
+}
+
+func newClientSecret() (secret, hash string, err error) {
+	secret, err = generateOpaqueToken()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate client secret: %w", err)
+	}
+	hash, err = utils.HashPassword(secret)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to hash client secret: %w", err)
+	}
+	return secret, hash, nil
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"sso/internal/logger"
+	"sso/internal/models"
+	"sso/internal/repository"
+	"sso/pkg/apperror"
+)
+
+// Consent is what a user agreed to be contacted over. A nil field means the
+// user did not say, which keeps the stored choice.
+type Consent struct {
+	Email   *bool
+	SMS     *bool
+	Webpush *bool
+}
+
+func (c Consent) get(channel string) *bool {
+	switch channel {
+	case models.ConsentEmail:
+		return c.Email
+	case models.ConsentSMS:
+		return c.SMS
+	case models.ConsentWebpush:
+		return c.Webpush
+	}
+	return nil
+}
+
+func (c *Consent) set(channel string, granted *bool) {
+	switch channel {
+	case models.ConsentEmail:
+		c.Email = granted
+	case models.ConsentSMS:
+		c.SMS = granted
+	case models.ConsentWebpush:
+		c.Webpush = granted
+	}
+}
+
+// ConsentOrigin is where a consent change was made. It goes to the audit trail
+// and, for changes that are synced on their own, to Mindbox.
+type ConsentOrigin struct {
+	Source     string
+	Platform   string
+	DeviceUUID string
+	Agent      string
+	IP         string
+}
+
+// ConsentService keeps the user's consent to be contacted, with an audit
+// trail of every change. Mindbox only gets the consent stored here.
+type ConsentService interface {
+	Get(userID int64) (Consent, error)
+	Record(userID int64, consent Consent, origin ConsentOrigin) (bool, error)
+	Update(userID int64, consent Consent, origin ConsentOrigin) (Consent, error)
+}
+
+type consentService struct {
+	userMindBoxRepo repository.UserMindBoxRepository
+	mindboxOutbox   MindboxOutboxService
+	logger          *logger.Logger
+}
+
+func NewConsentService(userMindBoxRepo repository.UserMindBoxRepository, mindboxOutbox MindboxOutboxService, logger *logger.Logger) ConsentService {
+	return &consentService{
+		userMindBoxRepo: userMindBoxRepo,
+		mindboxOutbox:   mindboxOutbox,
+		logger:          logger,
+	}
+}
+
+func (s *consentService) Get(userID int64) (Consent, error) {
+	userMindBox, err := s.userMindBoxRepo.FindByUserID(userID)
+	if err != nil {
+		return Consent{}, err
+	}
+	return storedConsent(userMindBox), nil
+}
+
+// Record stores consent and reports whether it changed anything. It does not
+// tell Mindbox; the caller sends an operation that carries it.
+func (s *consentService) Record(userID int64, consent Consent, origin ConsentOrigin) (bool, error) {
+	_, changed, err := s.record(userID, consent, origin)
+	return changed, err
+}
+
+// Update stores consent and, when it changed, queues it for Mindbox. It returns
+// the consent now stored.
+func (s *consentService) Update(userID int64, consent Consent, origin ConsentOrigin) (Consent, error) {
+	current, changed, err := s.record(userID, consent, origin)
+	if err != nil {
+		return Consent{}, err
+	}
+	if !changed {
+		return current, nil
+	}
+
+	err = s.mindboxOutbox.EnqueueSubscriptions(userID, MindboxEvent{
+		Platform:   origin.Platform,
+		DeviceUUID: origin.DeviceUUID,
+		Agent:      origin.Agent,
+	})
+	if err != nil {
+		// The consent is stored and goes out with the next login.
+		s.logger.Error("Failed to queue Mindbox subscriptions", "user_id", userID, "error", err)
+	}
+	return current, nil
+}
+
+func (s *consentService) record(userID int64, consent Consent, origin ConsentOrigin) (Consent, bool, error) {
+	userMindBox, err := s.userMindBoxRepo.FindByUserID(userID)
+	if err != nil {
+		return Consent{}, false, err
+	}
+	if userMindBox == nil {
+		if userMindBox, err = s.userMindBoxRepo.Create(userID); err != nil {
+			return Consent{}, false, err
+		}
+	}
+
+	current := storedConsent(userMindBox)
+	changes := consentChanges(userMindBox, consent, origin)
+	if len(changes) == 0 {
+		return current, false, nil
+	}
+
+	if err := s.userMindBoxRepo.UpdateConsent(userID, changes); err != nil {
+		return Consent{}, false, apperror.Internal(err, "error saving consent")
+	}
+	for _, c := range changes {
+		current.set(c.Channel, &c.Granted)
+		s.logger.Info("Consent changed",
+			"user_id", userID,
+			"channel", c.Channel,
+			"granted", c.Granted,
+			"source", c.Source)
+	}
+	return current, true, nil
+}
+
+// consentChanges returns the audit entries for the channels whose consent
+// differs from what userMindBox holds. A nil userMindBox holds none.
+func consentChanges(userMindBox *models.UserMindBox, consent Consent, origin ConsentOrigin) []models.UserConsent {
+	var changes []models.UserConsent
+	for _, channel := range models.ConsentChannels {
+		granted := consent.get(channel)
+		if granted == nil {
+			continue
+		}
+		if userMindBox != nil {
+			if stored := userMindBox.Consent(channel); stored.Valid && stored.Bool == *granted {
+				continue
+			}
+		}
+		changes = append(changes, models.UserConsent{
+			Channel:  channel,
+			Granted:  *granted,
+			Source:   origin.Source,
+			Platform: origin.Platform,
+			IP:       origin.IP,
+			Agent:    origin.Agent,
+		})
+	}
+	return changes
+}
+
+func storedConsent(userMindBox *models.UserMindBox) Consent {
+	var consent Consent
+	if userMindBox == nil {
+		return consent
+	}
+	for _, channel := range models.ConsentChannels {
+		if stored := userMindBox.Consent(channel); stored.Valid {
+			granted := stored.Bool
+			consent.set(channel, &granted)
+		}
+	}
+	return consent
+}
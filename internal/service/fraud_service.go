@@ -0,0 +1,384 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"sso/internal/config"
+	"sso/internal/logger"
+	"sso/pkg/apperror"
+)
+
+type FraudAction string
+
+const (
+	FraudActionChallenge FraudAction = "challenge"
+	FraudActionBlock     FraudAction = "block"
+)
+
+// FraudRule restricts code delivery to every phone starting with Prefix.
+// Automatic rules expire; manual ones stay until an admin removes them.
+type FraudRule struct {
+	Prefix     string      `json:"prefix"`
+	Action     FraudAction `json:"action"`
+	Manual     bool        `json:"manual"`
+	Sent       int64       `json:"sent,omitempty"`
+	Logins     int64       `json:"logins,omitempty"`
+	Conversion float64     `json:"conversion,omitempty"`
+	CreatedAt  time.Time   `json:"created_at"`
+	ExpiresAt  *time.Time  `json:"expires_at,omitempty"`
+}
+
+func (r *FraudRule) expired() bool {
+	return r.ExpiresAt != nil && time.Now().After(*r.ExpiresAt)
+}
+
+// FraudService scores code requests by how often codes sent to the same number
+// prefix, IP subnet and User-Agent end in a login. SMS pumping shows up as
+// many sends with almost no logins.
+type FraudService interface {
+	Assess(ctx context.Context, phone, ip, agent, captchaToken string) error
+	RecordSend(ctx context.Context, phone, ip, agent string)
+	RecordLogin(ctx context.Context, phone, ip, agent string)
+	Rules(ctx context.Context) ([]FraudRule, error)
+	SetRule(ctx context.Context, prefix string, action FraudAction) (*FraudRule, error)
+	DeleteRule(ctx context.Context, prefix string) error
+}
+
+type fraudService struct {
+	cache   CacheService
+	captcha CaptchaVerifier
+	cfg     config.FraudConfig
+	logger  *logger.Logger
+}
+
+func NewFraudService(cache CacheService, captcha CaptchaVerifier, cfg config.FraudConfig, logger *logger.Logger) FraudService {
+	return &fraudService{
+		cache:   cache,
+		captcha: captcha,
+		cfg:     cfg,
+		logger:  logger,
+	}
+}
+
+type fraudDimension struct {
+	kind  string
+	value string
+}
+
+func (d fraudDimension) subject(event string) string {
+	return "fraud:" + event + ":" + d.kind + ":" + d.value
+}
+
+// prefix takes the first digits of an E.164 phone, without the "+".
+func (s *fraudService) prefix(phone string) string {
+	phone = strings.TrimPrefix(phone, "+")
+	if len(phone) <= s.cfg.PrefixLength {
+		return phone
+	}
+	return phone[:s.cfg.PrefixLength]
+}
+
+// dimensions lists what a request is scored by. The number prefix comes first
+// because it is the only dimension rules are created for.
+func (s *fraudService) dimensions(phone, ip, agent string) []fraudDimension {
+	dims := []fraudDimension{{"prefix", s.prefix(phone)}}
+	if subnet := ipSubnet(ip); subnet != "" {
+		dims = append(dims, fraudDimension{"subnet", subnet})
+	}
+	if agent != "" {
+		dims = append(dims, fraudDimension{"ua", hashToken(agent)[:16]})
+	}
+	return dims
+}
+
+// ipSubnet groups addresses into the /24 (IPv4) or /48 (IPv6) they belong to.
+func ipSubnet(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String() + "/24"
+	}
+	return parsed.Mask(net.CIDRMask(48, 128)).String() + "/48"
+}
+
+// Assess rejects requests for blocked prefixes and asks for a captcha when the
+// prefix is challenged or the subnet or User-Agent converts poorly.
+func (s *fraudService) Assess(ctx context.Context, phone, ip, agent, captchaToken string) error {
+	if !s.cfg.Enabled {
+		return nil
+	}
+
+	rule, err := s.matchRule(ctx, phone)
+	if err != nil {
+		return apperror.Internal(err, "error checking fraud rules")
+	}
+
+	challenge := false
+	if rule != nil {
+		if rule.Action == FraudActionBlock {
+			s.logger.WarnContext(ctx, "Verification code blocked by fraud rule", "phone", phone, "prefix", rule.Prefix)
+			return apperror.ErrSMSBlocked
+		}
+		challenge = true
+	}
+
+	if !challenge {
+		for _, dim := range s.dimensions(phone, ip, agent)[1:] {
+			sent, logins, err := s.stats(ctx, dim)
+			if err != nil {
+				return apperror.Internal(err, "error checking conversion")
+			}
+			if s.converts(sent, logins) < s.cfg.ChallengeConversion {
+				s.logger.InfoContext(ctx, "Low conversion, captcha required", "dimension", dim.kind, "value", dim.value, "sent", sent, "logins", logins)
+				challenge = true
+				break
+			}
+		}
+	}
+
+	if !challenge {
+		return nil
+	}
+	// A challenge nobody can pass would stop the sends that let conversion
+	// recover, locking the prefix out until its rule expires.
+	if !s.captcha.Configured() {
+		s.logger.WarnContext(ctx, "Captcha challenge skipped: CAPTCHA_VERIFY_URL is not set", "phone", phone)
+		return nil
+	}
+	if captchaToken == "" {
+		return apperror.ErrCaptchaRequired
+	}
+
+	ok, err := s.captcha.Verify(captchaToken, ip)
+	if err != nil {
+		return apperror.Internal(err, "error verifying captcha")
+	}
+	if !ok {
+		return apperror.ErrInvalidCaptcha
+	}
+	return nil
+}
+
+// RecordSend counts a code the provider accepted and re-evaluates the phone's
+// prefix.
+func (s *fraudService) RecordSend(ctx context.Context, phone, ip, agent string) {
+	if !s.cfg.Enabled {
+		return
+	}
+
+	dims := s.dimensions(phone, ip, agent)
+	for _, dim := range dims {
+		if err := s.cache.RecordEvent(ctx, dim.subject("sent"), s.cfg.Window); err != nil {
+			s.logger.WarnContext(ctx, "Failed to record fraud event", "subject", dim.subject("sent"), "error", err)
+		}
+	}
+
+	if err := s.evaluatePrefix(ctx, dims[0]); err != nil {
+		s.logger.WarnContext(ctx, "Failed to evaluate number prefix", "prefix", dims[0].value, "error", err)
+	}
+}
+
+func (s *fraudService) RecordLogin(ctx context.Context, phone, ip, agent string) {
+	if !s.cfg.Enabled {
+		return
+	}
+
+	for _, dim := range s.dimensions(phone, ip, agent) {
+		if err := s.cache.RecordEvent(ctx, dim.subject("login"), s.cfg.Window); err != nil {
+			s.logger.WarnContext(ctx, "Failed to record fraud event", "subject", dim.subject("login"), "error", err)
+		}
+	}
+}
+
+// evaluatePrefix creates or escalates the automatic rule for a prefix whose
+// conversion fell below a threshold. Manual rules are left alone.
+func (s *fraudService) evaluatePrefix(ctx context.Context, dim fraudDimension) error {
+	sent, logins, err := s.stats(ctx, dim)
+	if err != nil {
+		return err
+	}
+
+	conversion := s.converts(sent, logins)
+	var action FraudAction
+	switch {
+	case conversion < s.cfg.BlockConversion:
+		action = FraudActionBlock
+	case conversion < s.cfg.ChallengeConversion:
+		action = FraudActionChallenge
+	default:
+		return nil
+	}
+
+	rules, err := s.loadRules(ctx, []string{dim.value})
+	if err != nil {
+		return err
+	}
+	if current, ok := rules[dim.value]; ok && !current.expired() {
+		if current.Manual || current.Action == action || current.Action == FraudActionBlock {
+			return nil
+		}
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(s.cfg.RuleTTL)
+	rule := &FraudRule{
+		Prefix:     dim.value,
+		Action:     action,
+		Sent:       sent,
+		Logins:     logins,
+		Conversion: conversion,
+		CreatedAt:  now,
+		ExpiresAt:  &expiresAt,
+	}
+	if err := s.saveRule(ctx, rule); err != nil {
+		return err
+	}
+
+	s.logger.WarnContext(ctx, "Fraud rule created",
+		"prefix", rule.Prefix,
+		"action", rule.Action,
+		"sent", sent,
+		"logins", logins,
+		"conversion", conversion)
+	return nil
+}
+
+func (s *fraudService) stats(ctx context.Context, dim fraudDimension) (sent, logins int64, err error) {
+	sent, _, err = s.cache.CountEvents(ctx, dim.subject("sent"), s.cfg.Window)
+	if err != nil {
+		return 0, 0, err
+	}
+	logins, _, err = s.cache.CountEvents(ctx, dim.subject("login"), s.cfg.Window)
+	if err != nil {
+		return 0, 0, err
+	}
+	return sent, logins, nil
+}
+
+// converts returns the login rate, or 1 while there are too few sends to judge.
+func (s *fraudService) converts(sent, logins int64) float64 {
+	if sent < int64(s.cfg.MinSamples) || sent == 0 {
+		return 1
+	}
+	return float64(logins) / float64(sent)
+}
+
+// matchRule returns the active rule with the longest prefix of phone.
+func (s *fraudService) matchRule(ctx context.Context, phone string) (*FraudRule, error) {
+	phone = strings.TrimPrefix(phone, "+")
+	prefixes := make([]string, 0, len(phone))
+	for i := len(phone); i > 0; i-- {
+		prefixes = append(prefixes, phone[:i])
+	}
+
+	rules, err := s.loadRules(ctx, prefixes)
+	if err != nil {
+		return nil, err
+	}
+	for _, prefix := range prefixes {
+		if rule, ok := rules[prefix]; ok && !rule.expired() {
+			return rule, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *fraudService) loadRules(ctx context.Context, prefixes []string) (map[string]*FraudRule, error) {
+	data, err := s.cache.GetFraudRules(ctx, prefixes)
+	if err != nil {
+		return nil, err
+	}
+	return s.decodeRules(ctx, data), nil
+}
+
+func (s *fraudService) decodeRules(ctx context.Context, data map[string][]byte) map[string]*FraudRule {
+	rules := make(map[string]*FraudRule, len(data))
+	for prefix, raw := range data {
+		var rule FraudRule
+		if err := json.Unmarshal(raw, &rule); err != nil {
+			s.logger.WarnContext(ctx, "Skipping malformed fraud rule", "prefix", prefix, "error", err)
+			continue
+		}
+		rules[prefix] = &rule
+	}
+	return rules
+}
+
+func (s *fraudService) saveRule(ctx context.Context, rule *FraudRule) error {
+	data, err := json.Marshal(rule)
+	if err != nil {
+		return err
+	}
+	return s.cache.SaveFraudRule(ctx, rule.Prefix, data)
+}
+
+// Rules lists the active rules, dropping expired ones on the way.
+func (s *fraudService) Rules(ctx context.Context) ([]FraudRule, error) {
+	data, err := s.cache.ListFraudRules(ctx)
+	if err != nil {
+		return nil, apperror.Internal(err, "error listing fraud rules")
+	}
+
+	rules := []FraudRule{}
+	for prefix, rule := range s.decodeRules(ctx, data) {
+		if rule.expired() {
+			if err := s.cache.DeleteFraudRule(ctx, prefix); err != nil {
+				s.logger.WarnContext(ctx, "Failed to delete expired fraud rule", "prefix", prefix, "error", err)
+			}
+			continue
+		}
+		rules = append(rules, *rule)
+	}
+
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].Prefix < rules[j].Prefix
+	})
+	return rules, nil
+}
+
+// SetRule creates or replaces a manual rule for prefix.
+func (s *fraudService) SetRule(ctx context.Context, prefix string, action FraudAction) (*FraudRule, error) {
+	if prefix == "" || !isDigits(prefix) {
+		return nil, apperror.ErrInvalidFraudRule.WithMessage("prefix must be a non-empty string of digits")
+	}
+	if action != FraudActionBlock && action != FraudActionChallenge {
+		return nil, apperror.ErrInvalidFraudRule.WithMessage("action must be block or challenge")
+	}
+
+	rule := &FraudRule{
+		Prefix:    prefix,
+		Action:    action,
+		Manual:    true,
+		CreatedAt: time.Now(),
+	}
+	if err := s.saveRule(ctx, rule); err != nil {
+		return nil, apperror.Internal(err, "error saving fraud rule")
+	}
+
+	s.logger.InfoContext(ctx, "Fraud rule set manually", "prefix", prefix, "action", action)
+	return rule, nil
+}
+
+func (s *fraudService) DeleteRule(ctx context.Context, prefix string) error {
+	if err := s.cache.DeleteFraudRule(ctx, prefix); err != nil {
+		return apperror.Internal(err, "error deleting fraud rule")
+	}
+	s.logger.InfoContext(ctx, "Fraud rule deleted", "prefix", prefix)
+	return nil
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
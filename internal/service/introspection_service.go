@@ -0,0 +1,164 @@
+package service
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"sso/internal/logger"
+	"sso/internal/repository"
+	"sso/pkg/apperror"
+)
+
+const (
+	TokenTypeHintAccessToken  = "access_token"
+	TokenTypeHintRefreshToken = "refresh_token"
+)
+
+// IntrospectionResponse is an RFC 7662 response. Inactive tokens carry only
+// Active, so nothing about them leaks to the caller.
+type IntrospectionResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	Iss       string `json:"iss,omitempty"`
+	Phone     string `json:"phone,omitempty"`
+}
+
+type IntrospectionService interface {
+	Introspect(clientID, clientSecret, token, tokenTypeHint string) (*IntrospectionResponse, error)
+}
+
+type introspectionService struct {
+	clientService ClientService
+	jwtService    JWTService
+	tokenRepo     repository.TokenRepository
+	issuer        string
+	logger        *logger.Logger
+}
+
+func NewIntrospectionService(
+	clientService ClientService,
+	jwtService JWTService,
+	tokenRepo repository.TokenRepository,
+	issuer string,
+	logger *logger.Logger,
+) IntrospectionService {
+	return &introspectionService{
+		clientService: clientService,
+		jwtService:    jwtService,
+		tokenRepo:     tokenRepo,
+		issuer:        strings.TrimSuffix(issuer, "/"),
+		logger:        logger,
+	}
+}
+
+// Introspect reports whether token is currently usable. Only confidential
+// clients may introspect. Access tokens must pass the signature check, must
+// not be blacklisted, alone or through their session, and must not be
+// deactivated in user_access_tokens; the token_type_hint only changes which
+// kind is tried first.
+func (s *introspectionService) Introspect(clientID, clientSecret, token, tokenTypeHint string) (*IntrospectionResponse, error) {
+	client, err := s.clientService.Authenticate(clientID, clientSecret)
+	if errors.Is(err, apperror.ErrInvalidClient) {
+		s.logger.Warn("Introspection client authentication failed", "client_id", clientID, "error", err)
+		return nil, oauthError("invalid_client", "client authentication failed")
+	}
+	if err != nil {
+		s.logger.Error("Failed to authenticate client", "client_id", clientID, "error", err)
+		return nil, oauthError("server_error", "failed to authenticate client")
+	}
+	if !client.IsConfidential() {
+		return nil, oauthError("invalid_client", "only confidential clients may introspect tokens")
+	}
+
+	if token == "" {
+		return nil, oauthError("invalid_request", "token is required")
+	}
+
+	lookups := []func(string) (*IntrospectionResponse, error){s.introspectAccessToken, s.introspectRefreshToken}
+	if tokenTypeHint == TokenTypeHintRefreshToken {
+		lookups[0], lookups[1] = lookups[1], lookups[0]
+	}
+
+	for _, lookup := range lookups {
+		result, err := lookup(token)
+		if err != nil {
+			s.logger.Error("Token introspection failed", "client_id", clientID, "error", err)
+			return nil, oauthError("server_error", "failed to introspect token")
+		}
+		if result != nil {
+			return result, nil
+		}
+	}
+
+	return &IntrospectionResponse{Active: false}, nil
+}
+
+func (s *introspectionService) introspectAccessToken(token string) (*IntrospectionResponse, error) {
+	parsed, err := s.jwtService.ValidateToken(token)
+	if err != nil {
+		return nil, nil
+	}
+	claims, ok := parsed.Claims.(*Claims)
+	if !ok {
+		return nil, nil
+	}
+
+	// Only the latest token of a session is recorded; earlier ones are judged
+	// by signature and blacklist alone, which covers their revoked session.
+	// A recorded token is inactive once its row is deactivated.
+	row, err := s.tokenRepo.FindAnyByToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if row != nil && row.ExpireAt.Valid && !row.ExpireAt.Time.After(time.Now()) {
+		return &IntrospectionResponse{Active: false}, nil
+	}
+
+	result := &IntrospectionResponse{
+		Active:    true,
+		Scope:     claims.Scope,
+		ClientID:  claims.ClientID,
+		TokenType: "Bearer",
+		Sub:       claims.Subject,
+		Iss:       s.issuer,
+		Phone:     claims.Phone,
+	}
+	if claims.ExpiresAt != nil {
+		result.Exp = claims.ExpiresAt.Unix()
+	}
+	if claims.IssuedAt != nil {
+		result.Iat = claims.IssuedAt.Unix()
+	}
+	return result, nil
+}
+
+func (s *introspectionService) introspectRefreshToken(token string) (*IntrospectionResponse, error) {
+	row, err := s.tokenRepo.FindRefreshToken(hashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if row == nil {
+		return nil, nil
+	}
+	if row.RotatedAt.Valid || row.RevokedAt.Valid || !row.ExpireAt.After(time.Now()) {
+		return &IntrospectionResponse{Active: false}, nil
+	}
+
+	return &IntrospectionResponse{
+		Active:    true,
+		Scope:     row.Scope.String,
+		ClientID:  row.ClientID.String,
+		TokenType: TokenTypeHintRefreshToken,
+		Exp:       row.ExpireAt.Unix(),
+		Iat:       row.CreatedAt.Unix(),
+		Sub:       strconv.FormatInt(row.UserID, 10),
+		Iss:       s.issuer,
+	}, nil
+}
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const keyFileExt = ".pem"
+
+// keyring holds one active signing key, the verify-only keys it replaced and
+// the next key, if one is being published ahead of a rotation. A replaced key
+// keeps verifying until its retireAt, so tokens signed right before a rotation
+// survive it.
+type keyring struct {
+	mu     sync.RWMutex
+	active *signingKey
+	keys   map[string]*signingKey
+}
+
+func newKeyring(active *signingKey) *keyring {
+	k := &keyring{}
+	k.replace(active, nil)
+	return k
+}
+
+func (k *keyring) replace(active *signingKey, verifyOnly []*signingKey) {
+	keys := make(map[string]*signingKey, len(verifyOnly)+1)
+	for _, key := range verifyOnly {
+		keys[key.kid] = key
+	}
+	keys[active.kid] = active
+
+	k.mu.Lock()
+	k.active = active
+	k.keys = keys
+	k.mu.Unlock()
+}
+
+func (k *keyring) Active() *signingKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.active
+}
+
+// Lookup returns the key for kid unless it has been retired.
+func (k *keyring) Lookup(kid string) (*signingKey, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	key, ok := k.keys[kid]
+	if !ok || (!key.retireAt.IsZero() && time.Now().After(key.retireAt)) {
+		return nil, false
+	}
+	return key, true
+}
+
+// Keys returns every key that still verifies, active key first.
+func (k *keyring) Keys() []*signingKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	now := time.Now()
+	keys := []*signingKey{k.active}
+	for _, key := range k.keys {
+		if key == k.active || (!key.retireAt.IsZero() && now.After(key.retireAt)) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// JWKSMaxAge is how long clients may cache the JWKS document. A new key is
+// published that long before it starts signing, so that no client sees a kid
+// it has not fetched yet.
+const JWKSMaxAge = 5 * time.Minute
+
+// keyFile is a key as found in the key directory.
+type keyFile struct {
+	key  *signingKey
+	path string
+}
+
+// loadKeyDir reads every *.pem in dir, named <kid>.<unix creation time>.pem;
+// files named only after their kid fall back to the modification time. The
+// newest key created at least JWKSMaxAge ago is the active key, newer ones are
+// published but do not sign yet, and each older key retires grace after its
+// successor became active. Files whose grace period is over are returned as
+// expired so the caller can remove them.
+func loadKeyDir(dir, alg string, grace time.Duration) (active *signingKey, verifyOnly []*signingKey, expired []string, err error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to read key directory %s: %w", dir, err)
+	}
+
+	var files []keyFile
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != keyFileExt {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		kid, createdAt, ok := parseKeyFileName(entry.Name())
+		if !ok {
+			info, err := entry.Info()
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("failed to stat key %s: %w", path, err)
+			}
+			createdAt = info.ModTime()
+		}
+
+		key, err := loadSigningKey(alg, path, kid)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		key.createdAt = createdAt
+		files = append(files, keyFile{key: key, path: path})
+	}
+
+	if len(files) == 0 {
+		return nil, nil, nil, nil
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].key.createdAt.After(files[j].key.createdAt)
+	})
+
+	// The oldest key signs when every key is still being published, as when
+	// the directory was just created.
+	now := time.Now()
+	current := len(files) - 1
+	for i, file := range files {
+		if !now.Before(file.key.createdAt.Add(JWKSMaxAge)) {
+			current = i
+			break
+		}
+	}
+
+	for i, file := range files {
+		if i < current {
+			verifyOnly = append(verifyOnly, file.key)
+			continue
+		}
+		if i == current {
+			continue
+		}
+		file.key.retireAt = files[i-1].key.createdAt.Add(JWKSMaxAge + grace)
+		if now.After(file.key.retireAt) {
+			expired = append(expired, file.path)
+			continue
+		}
+		verifyOnly = append(verifyOnly, file.key)
+	}
+
+	return files[current].key, verifyOnly, expired, nil
+}
+
+// parseKeyFileName splits <kid>.<unix creation time>.pem.
+func parseKeyFileName(name string) (kid string, createdAt time.Time, ok bool) {
+	name = strings.TrimSuffix(name, keyFileExt)
+	i := strings.LastIndexByte(name, '.')
+	if i <= 0 {
+		return name, time.Time{}, false
+	}
+	sec, err := strconv.ParseInt(name[i+1:], 10, 64)
+	if err != nil {
+		return name, time.Time{}, false
+	}
+	return name[:i], time.Unix(sec, 0), true
+}
+
+// writeKeyFile stores a generated key atomically so that other instances
+// sharing the directory never read a partially written file.
+func writeKeyFile(dir string, key *signingKey, data []byte) error {
+	tmp, err := os.CreateTemp(dir, ".key-*")
+	if err != nil {
+		return fmt.Errorf("failed to create key file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write key file: %w", err)
+	}
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to chmod key file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close key file: %w", err)
+	}
+
+	name := fmt.Sprintf("%s.%d%s", key.kid, key.createdAt.Unix(), keyFileExt)
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, name)); err != nil {
+		return fmt.Errorf("failed to install key file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// signingKey is a single key the service can sign or verify tokens with.
+// For HMAC keys private and public hold the same shared secret.
+type signingKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	private any
+	public  any
+
+	// createdAt orders keys in a keyring; retireAt is set once a newer key
+	// takes over signing and marks the end of the verify-only window.
+	createdAt time.Time
+	retireAt  time.Time
+}
+
+// JWK is the public part of a signing key as published in the JWKS document.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+func newHMACKey(kid, secret string) *signingKey {
+	if kid == "" {
+		kid = "hs256"
+	}
+	return &signingKey{
+		kid:     kid,
+		method:  jwt.SigningMethodHS256,
+		private: []byte(secret),
+		public:  []byte(secret),
+	}
+}
+
+// loadSigningKey reads a PEM encoded RSA or ECDSA private key. When kid is empty
+// the RFC 7638 thumbprint of the public key is used, so every instance loading
+// the same file advertises the same kid.
+func loadSigningKey(alg, path, kid string) (*signingKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key %s: %w", path, err)
+	}
+	return parseSigningKey(alg, data, kid)
+}
+
+func parseSigningKey(alg string, data []byte, kid string) (*signingKey, error) {
+	method := jwt.GetSigningMethod(alg)
+	if method == nil {
+		return nil, fmt.Errorf("unsupported signing algorithm: %s", alg)
+	}
+
+	key := &signingKey{kid: kid, method: method}
+
+	switch method.(type) {
+	case *jwt.SigningMethodRSA:
+		privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse RSA private key: %w", err)
+		}
+		key.private = privateKey
+		key.public = &privateKey.PublicKey
+	case *jwt.SigningMethodECDSA:
+		privateKey, err := jwt.ParseECPrivateKeyFromPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse ECDSA private key: %w", err)
+		}
+		if privateKey.Curve.Params().BitSize != method.(*jwt.SigningMethodECDSA).CurveBits {
+			return nil, fmt.Errorf("curve %s does not match algorithm %s", privateKey.Curve.Params().Name, alg)
+		}
+		key.private = privateKey
+		key.public = &privateKey.PublicKey
+	default:
+		return nil, fmt.Errorf("algorithm %s requires a key pair, got %T", alg, method)
+	}
+
+	if key.kid == "" {
+		jwk, err := key.jwk()
+		if err != nil {
+			return nil, err
+		}
+		key.kid = jwkThumbprint(jwk)
+	}
+
+	return key, nil
+}
+
+// generateSigningKey creates a fresh key pair for alg and returns it together
+// with its PKCS #8 PEM encoding.
+func generateSigningKey(alg string) (*signingKey, []byte, error) {
+	method := jwt.GetSigningMethod(alg)
+	if method == nil {
+		return nil, nil, fmt.Errorf("unsupported signing algorithm: %s", alg)
+	}
+
+	var privateKey any
+	var err error
+	switch m := method.(type) {
+	case *jwt.SigningMethodRSA:
+		privateKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	case *jwt.SigningMethodECDSA:
+		var curve elliptic.Curve
+		switch m.CurveBits {
+		case 256:
+			curve = elliptic.P256()
+		case 384:
+			curve = elliptic.P384()
+		case 521:
+			curve = elliptic.P521()
+		}
+		privateKey, err = ecdsa.GenerateKey(curve, rand.Reader)
+	default:
+		return nil, nil, fmt.Errorf("algorithm %s requires a key pair, got %T", alg, method)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate %s key: %w", alg, err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode private key: %w", err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	key, err := parseSigningKey(alg, data, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	key.createdAt = time.Now()
+
+	return key, data, nil
+}
+
+func (k *signingKey) isSymmetric() bool {
+	_, ok := k.method.(*jwt.SigningMethodHMAC)
+	return ok
+}
+
+func (k *signingKey) jwk() (JWK, error) {
+	switch pub := k.public.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			Kid: k.kid,
+			Use: "sig",
+			Alg: k.method.Alg(),
+			N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}, nil
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		return JWK{
+			Kty: "EC",
+			Kid: k.kid,
+			Use: "sig",
+			Alg: k.method.Alg(),
+			Crv: pub.Curve.Params().Name,
+			X:   base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, size))),
+			Y:   base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, size))),
+		}, nil
+	default:
+		return JWK{}, fmt.Errorf("key %s cannot be published", k.kid)
+	}
+}
+
+// jwkThumbprint implements RFC 7638: the SHA-256 of the required members in
+// lexicographic order.
+func jwkThumbprint(jwk JWK) string {
+	var members any
+	switch jwk.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	default:
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{jwk.Crv, jwk.Kty, jwk.X, jwk.Y}
+	}
+
+	b, _ := json.Marshal(members)
+	sum := sha256.Sum256(b)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
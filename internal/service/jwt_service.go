@@ -3,46 +3,251 @@ package service
 import (
 	"context"
 	"fmt"
+	"os"
+	"sso/internal/config"
+	"sso/internal/logger"
+	"sso/internal/repository"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
 type JWTService interface {
-	GenerateToken(userID int64, phone string) (string, error)
+	GenerateClientToken(userID int64, phone, clientID, scope, familyID string) (string, error)
+	SignClaims(claims jwt.Claims) (string, error)
 	ValidateToken(tokenString string) (*jwt.Token, error)
+	AccessTTL() time.Duration
+	Asymmetric() bool
+	JWKS() JWKS
+	RotateKeys() error
 }
 
+// keyReloadInterval limits how often an unknown kid makes the service re-read
+// the key directory looking for a key another instance has just rotated in.
+const keyReloadInterval = 10 * time.Second
+
+// accessTokenType is the typ header of access tokens (RFC 9068). It tells them
+// apart from ID tokens, which are signed with the same keys.
+const accessTokenType = "at+jwt"
+
 type jwtService struct {
-	secretKey []byte
+	keys      *keyring
+	legacyKey *signingKey
+	accessTTL time.Duration
 	codeCache CacheService
+	tokenRepo repository.TokenRepository
+	logger    *logger.Logger
+
+	alg              string
+	keysDir          string
+	rotationInterval time.Duration
+	gracePeriod      time.Duration
+
+	reloadMu   sync.Mutex
+	lastReload time.Time
 }
 
-func NewJWTService(secretKey string, codeCache CacheService) JWTService {
-	return &jwtService{
-		secretKey: []byte(secretKey),
-		codeCache: codeCache,
+func NewJWTService(cfg config.JWTConfig, codeCache CacheService, tokenRepo repository.TokenRepository, logger *logger.Logger) (JWTService, error) {
+	s := &jwtService{
+		accessTTL:        cfg.AccessTTL,
+		codeCache:        codeCache,
+		tokenRepo:        tokenRepo,
+		logger:           logger,
+		alg:              cfg.SigningAlg,
+		rotationInterval: cfg.KeyRotationInterval,
+		gracePeriod:      cfg.KeyGracePeriod,
+	}
+
+	// Tokens issued before kid was introduced carry no kid and were signed with
+	// the shared secret; they stay valid until they expire. They also predate
+	// the typ header and the session claim, see validateLegacyToken.
+	if cfg.SecretKey != "" {
+		s.legacyKey = newHMACKey("", cfg.SecretKey)
 	}
+
+	if cfg.SigningAlg == "" || cfg.SigningAlg == jwt.SigningMethodHS256.Alg() {
+		if cfg.SecretKey == "" {
+			return nil, fmt.Errorf("JWT_SECRET_KEY is required for %s", jwt.SigningMethodHS256.Alg())
+		}
+		s.keys = newKeyring(newHMACKey(cfg.KeyID, cfg.SecretKey))
+		return s, nil
+	}
+
+	if cfg.KeysDir != "" {
+		if s.gracePeriod < s.accessTTL {
+			return nil, fmt.Errorf("JWT_KEY_GRACE_PERIOD (%s) must not be shorter than JWT_ACCESS_TTL (%s)", s.gracePeriod, s.accessTTL)
+		}
+		s.keysDir = cfg.KeysDir
+		if err := s.reloadKeys(); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+
+	key, err := loadSigningKey(cfg.SigningAlg, cfg.PrivateKeyPath, cfg.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	s.keys = newKeyring(key)
+
+	return s, nil
 }
 
 type Claims struct {
-	UserID int64  `json:"user_id"`
-	Phone  string `json:"phone"`
+	UserID   int64  `json:"user_id"`
+	Phone    string `json:"phone"`
+	ClientID string `json:"client_id,omitempty"`
+	Scope    string `json:"scope,omitempty"`
+	// FamilyID is the refresh token family, and so the session, the token
+	// was issued to.
+	FamilyID string `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-func (s *jwtService) GenerateToken(userID int64, phone string) (string, error) {
+// GenerateClientToken issues an access token on behalf of an OAuth client.
+// First-party logins leave clientID and scope empty.
+func (s *jwtService) GenerateClientToken(userID int64, phone, clientID, scope, familyID string) (string, error) {
 	claims := &Claims{
-		UserID: userID,
-		Phone:  phone,
+		UserID:   userID,
+		Phone:    phone,
+		ClientID: clientID,
+		Scope:    scope,
+		FamilyID: familyID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)), // Token expires in 24 hours
+			Subject:   strconv.FormatInt(userID, 10),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.accessTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(s.secretKey)
+	return s.sign(claims, accessTokenType)
+}
+
+// SignClaims signs arbitrary claims, such as an ID token, with the active key.
+// The result is never accepted as an access token.
+func (s *jwtService) SignClaims(claims jwt.Claims) (string, error) {
+	return s.sign(claims, "JWT")
+}
+
+func (s *jwtService) sign(claims jwt.Claims, typ string) (string, error) {
+	key := s.keys.Active()
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.kid
+	token.Header["typ"] = typ
+	return token.SignedString(key.private)
+}
+
+func (s *jwtService) AccessTTL() time.Duration {
+	return s.accessTTL
+}
+
+// Asymmetric reports whether tokens are signed with a key pair, so that others
+// can verify them with the keys published in JWKS.
+func (s *jwtService) Asymmetric() bool {
+	return !s.keys.Active().isSymmetric()
+}
+
+// JWKS returns the public keys downstream services verify tokens with,
+// including verify-only keys that are still within their grace period.
+// Shared HMAC secrets are never published.
+func (s *jwtService) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	for _, key := range s.keys.Keys() {
+		if key.isSymmetric() {
+			continue
+		}
+		if jwk, err := key.jwk(); err == nil {
+			jwks.Keys = append(jwks.Keys, jwk)
+		}
+	}
+	return jwks
+}
+
+// RotateKeys generates the next key once the active one is older than the
+// rotation interval. The next key is published in JWKS first and takes over
+// signing JWKSMaxAge later, on a following run; the previous key then stays
+// verify-only for the grace period. Keys only rotate when they are managed
+// through a key directory.
+func (s *jwtService) RotateKeys() error {
+	if s.keysDir == "" {
+		return nil
+	}
+
+	if err := s.reloadKeys(); err != nil {
+		return err
+	}
+
+	active := s.keys.Active()
+	if time.Since(active.createdAt) < s.rotationInterval {
+		return nil
+	}
+	for _, key := range s.keys.Keys() {
+		if key.createdAt.After(active.createdAt) {
+			return nil
+		}
+	}
+
+	key, data, err := generateSigningKey(s.alg)
+	if err != nil {
+		return err
+	}
+	if err := writeKeyFile(s.keysDir, key, data); err != nil {
+		return err
+	}
+	if err := s.reloadKeys(); err != nil {
+		return err
+	}
+
+	s.logger.Info("JWT signing key published",
+		"kid", key.kid,
+		"active_at", key.createdAt.Add(JWKSMaxAge))
+	return nil
+}
+
+// reloadKeys re-reads the key directory, generating the first key when it is
+// empty and deleting keys whose grace period is over.
+func (s *jwtService) reloadKeys() error {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	active, verifyOnly, expired, err := loadKeyDir(s.keysDir, s.alg, s.gracePeriod)
+	if err != nil {
+		return err
+	}
+
+	if active == nil {
+		key, data, err := generateSigningKey(s.alg)
+		if err != nil {
+			return err
+		}
+		if err := writeKeyFile(s.keysDir, key, data); err != nil {
+			return err
+		}
+		s.logger.Info("JWT signing key generated", "kid", key.kid, "dir", s.keysDir)
+		active = key
+	}
+
+	for _, path := range expired {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			s.logger.Warn("Failed to remove retired JWT signing key", "path", path, "error", err)
+			continue
+		}
+		s.logger.Info("JWT signing key retired", "path", path)
+	}
+
+	if s.keys == nil {
+		s.keys = newKeyring(active)
+	} else if previous := s.keys.Active(); previous.kid != active.kid {
+		s.logger.Info("JWT signing key rotated",
+			"kid", active.kid,
+			"previous_kid", previous.kid,
+			"previous_retire_at", active.createdAt.Add(JWKSMaxAge+s.gracePeriod))
+	}
+	s.keys.replace(active, verifyOnly)
+	s.lastReload = time.Now()
+	return nil
 }
 
 func (s *jwtService) ValidateToken(tokenString string) (*jwt.Token, error) {
@@ -54,15 +259,98 @@ func (s *jwtService) ValidateToken(tokenString string) (*jwt.Token, error) {
 		return nil, fmt.Errorf("token is blacklisted")
 	}
 
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return s.secretKey, nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, s.keyFunc)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse token: %w", err)
 	}
+	// keyFunc only accepts a token without kid when it is signed with the
+	// legacy key. ID tokens always carry one.
+	if kid, _ := token.Header["kid"].(string); kid == "" {
+		if err := s.validateLegacyToken(tokenString); err != nil {
+			return nil, err
+		}
+		return token, nil
+	}
+
+	if typ, _ := token.Header["typ"].(string); typ != accessTokenType {
+		return nil, fmt.Errorf("not an access token")
+	}
+
+	// A refresh replaces the token recorded for the session, so tokens issued
+	// earlier are revoked through their family rather than one by one.
+	claims, ok := token.Claims.(*Claims)
+	if !ok || claims.FamilyID == "" {
+		return nil, fmt.Errorf("token has no session")
+	}
+	if err := s.checkFamily(claims.FamilyID); err != nil {
+		return nil, err
+	}
 
 	return token, nil
 }
+
+// validateLegacyToken judges a token issued before kid was introduced by its
+// user_access_tokens row, as it carries no session claim. Tokens that were
+// never recorded only have the blacklist.
+func (s *jwtService) validateLegacyToken(tokenString string) error {
+	row, err := s.tokenRepo.FindAnyByToken(tokenString)
+	if err != nil {
+		return fmt.Errorf("failed to look up token: %w", err)
+	}
+	if row == nil {
+		return nil
+	}
+	if row.ExpireAt.Valid && !row.ExpireAt.Time.After(time.Now()) {
+		return fmt.Errorf("token is deactivated")
+	}
+	if row.FamilyID.Valid && row.FamilyID.String != "" {
+		return s.checkFamily(row.FamilyID.String)
+	}
+	return nil
+}
+
+func (s *jwtService) checkFamily(familyID string) error {
+	revoked, err := s.codeCache.IsFamilyBlacklisted(context.Background(), familyID)
+	if err != nil {
+		return fmt.Errorf("failed to check token blacklist: %w", err)
+	}
+	if revoked {
+		return fmt.Errorf("token session is revoked")
+	}
+	return nil
+}
+
+func (s *jwtService) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	var key *signingKey
+	if kid == "" && s.legacyKey != nil {
+		key = s.legacyKey
+	} else {
+		var ok bool
+		key, ok = s.keys.Lookup(kid)
+		if !ok && s.reloadDue() {
+			if err := s.reloadKeys(); err != nil {
+				s.logger.Warn("Failed to reload JWT signing keys", "error", err)
+			}
+			key, ok = s.keys.Lookup(kid)
+		}
+		if !ok {
+			return nil, fmt.Errorf("unknown key id: %v", token.Header["kid"])
+		}
+	}
+
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.public, nil
+}
+
+func (s *jwtService) reloadDue() bool {
+	if s.keysDir == "" {
+		return false
+	}
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	return time.Since(s.lastReload) > keyReloadInterval
+}
@@ -1,232 +1,178 @@
 package service
 
 import (
-	"bytes"
-	"crypto/tls"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"io"
-	"net/http"
-	"sso/internal/config"
 	"sso/internal/logger"
 	"sso/internal/models"
 	"sso/internal/repository"
-	"time"
+	"sso/pkg/mindbox"
+	"strconv"
 )
 
 type AuthMindboxService interface {
 	RegisterUser(user *models.User, platform string, websiteID string, deviceUUID string, userAgent string) error
-	LoginUser(user *models.User, platform string, websiteID string, mindboxID string, deviceUUID string, userAgent string) error
+	LoginUser(user *models.User, platform string, websiteID string, deviceUUID string, userAgent string) error
+	UpdateSubscriptions(user *models.User, platform string, websiteID string, deviceUUID string, userAgent string) error
+}
+
+// mindboxPointsOfContact maps consent channels to Mindbox points of contact.
+var mindboxPointsOfContact = map[string]string{
+	models.ConsentEmail:   mindbox.PointOfContactEmail,
+	models.ConsentSMS:     mindbox.PointOfContactSMS,
+	models.ConsentWebpush: mindbox.PointOfContactWebpush,
 }
 
 type authMindboxService struct {
 	userRepo        repository.UserRepository
 	userMindBoxRepo repository.UserMindBoxRepository
 	log             *logger.Logger
-	cfg             *config.Config
-	client          *http.Client
-}
-
-func createHTTPClient(cfg *config.Config) *http.Client {
-	transport := &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: false,
-		},
-	}
-
-	return &http.Client{
-		Transport: transport,
-		Timeout:   cfg.TLS.Timeout,
-	}
+	client          *mindbox.Client
 }
 
-func NewAuthMindboxService(log *logger.Logger, userRepo repository.UserRepository, userMindBoxRepo repository.UserMindBoxRepository, cfg *config.Config) AuthMindboxService {
-	client := createHTTPClient(cfg)
-
-	log.Debug("Mindbox HTTP client configured",
-		"tls_skip_verify", false,
-		"timeout", cfg.TLS.Timeout,
-		"environment", cfg.AppEnv)
-
+func NewAuthMindboxService(log *logger.Logger, userRepo repository.UserRepository, userMindBoxRepo repository.UserMindBoxRepository, client *mindbox.Client) AuthMindboxService {
 	return &authMindboxService{
 		userRepo:        userRepo,
 		userMindBoxRepo: userMindBoxRepo,
 		log:             log,
-		cfg:             cfg,
 		client:          client,
 	}
 }
 
 func (s *authMindboxService) RegisterUser(user *models.User, platform string, websiteID string, deviceUUID string, userAgent string) error {
-	userMindBox, err := s.userMindBoxRepo.FindByUserID(user.ID)
-	if err != nil {
-		s.log.Warn("Failed to check user in user_mind_box", "user_id", user.ID, "error", err)
-	} else if userMindBox == nil {
-		var createErr error
-		_, createErr = s.userMindBoxRepo.Create(user.ID)
-		if createErr != nil {
-			s.log.Warn("Failed to create user in user_mind_box", "user_id", user.ID, "error", createErr)
-		} else {
-			s.log.Debug("Created user record in user_mind_box", "user_id", user.ID)
-		}
-	}
-
-	customerData := map[string]any{
-		"mobilePhone": user.Phone.String,
-		"ids": map[string]string{
-			"websiteID": websiteID,
-		},
-		"subscriptions": []map[string]any{
-			{"pointOfContact": "Email", "isSubscribed": 1},
-			{"pointOfContact": "SMS", "isSubscribed": 1},
-			{"pointOfContact": "Webpush", "isSubscribed": 1},
-		},
-	}
-
-	if user.FirstName.Valid && user.FirstName.String != "" {
-		customerData["firstName"] = user.FirstName.String
-	}
-	if user.LastName.Valid && user.LastName.String != "" {
-		customerData["lastName"] = user.LastName.String
-	}
-	if user.Email.Valid && user.Email.String != "" {
-		customerData["email"] = user.Email.String
-	}
+	customer := s.customer(user, websiteID, s.userMindBox(user.ID), false)
 
-	requestBody := map[string]any{
-		"customer":             customerData,
-		"executionDateTimeUtc": time.Now().UTC().Format("2006-01-02 15:04:05.000"),
-	}
-
-	err = s.Send(platform, "RegisterCustomer", requestBody, deviceUUID, userAgent)
+	resp, err := s.client.RegisterCustomer(mindboxDevice(platform, deviceUUID, userAgent), customer)
 	if err != nil {
 		s.log.Error("failed to send RegisterUser to mindbox", "error", err.Error())
 		return fmt.Errorf("failed to register user in mindbox: %w", err)
 	}
 
+	s.saveCustomer(user.ID, resp)
 	return nil
 }
 
-func (s *authMindboxService) LoginUser(user *models.User, platform string, websiteID string, mindboxID string, deviceUUID string, userAgent string) error {
-	userMindBox, err := s.userMindBoxRepo.FindByUserID(user.ID)
-	if err != nil {
-		s.log.Warn("Failed to check user in user_mind_box", "user_id", user.ID, "error", err)
-	} else if userMindBox == nil {
-		var createErr error
-		_, createErr = s.userMindBoxRepo.Create(user.ID)
-		if createErr != nil {
-			s.log.Warn("Failed to create user in user_mind_box", "user_id", user.ID, "error", createErr)
-		} else {
-			s.log.Debug("Created user record in user_mind_box", "user_id", user.ID)
-		}
-	}
-
-	customerData := map[string]any{
-		"mobilePhone": user.Phone.String,
-		"ids": map[string]string{
-			"websiteID": websiteID,
-		},
-		"subscriptions": []map[string]any{
-			{"pointOfContact": "Email", "isSubscribed": 1},
-			{"pointOfContact": "SMS", "isSubscribed": 1},
-			{"pointOfContact": "Webpush", "isSubscribed": 1},
-		},
-	}
+// LoginUser sends the Mindbox customer id stored for the user, if there is one,
+// so that Mindbox matches the existing customer instead of creating another.
+func (s *authMindboxService) LoginUser(user *models.User, platform string, websiteID string, deviceUUID string, userAgent string) error {
+	customer := s.customer(user, websiteID, s.userMindBox(user.ID), true)
 
-	if mindboxID != "" {
-		customerData["ids"].(map[string]string)["mindboxId"] = mindboxID
+	resp, err := s.client.AuthorizeCustomer(mindboxDevice(platform, deviceUUID, userAgent), customer)
+	if err != nil {
+		s.log.Error("failed to send LoginUser to mindbox", "error", err.Error())
+		return fmt.Errorf("failed to login user in mindbox: %w", err)
 	}
 
-	if user.FirstName.Valid && user.FirstName.String != "" {
-		customerData["firstName"] = user.FirstName.String
-	}
-	if user.LastName.Valid && user.LastName.String != "" {
-		customerData["lastName"] = user.LastName.String
-	}
-	if user.Email.Valid && user.Email.String != "" {
-		customerData["email"] = user.Email.String
-	}
+	s.saveCustomer(user.ID, resp)
+	return nil
+}
 
-	requestBody := map[string]any{
-		"customer":             customerData,
-		"executionDateTimeUtc": time.Now().UTC().Format("2006-01-02 15:04:05.000"),
+// UpdateSubscriptions sends the consent the user changed since the last
+// operation.
+func (s *authMindboxService) UpdateSubscriptions(user *models.User, platform string, websiteID string, deviceUUID string, userAgent string) error {
+	userMindBox, err := s.userMindBoxRepo.FindByUserID(user.ID)
+	if err != nil {
+		return err
 	}
+	customer := s.customer(user, websiteID, userMindBox, true)
 
-	err = s.Send(platform, "AuthorizeCustomer", requestBody, deviceUUID, userAgent)
+	resp, err := s.client.EditCustomer(mindboxDevice(platform, deviceUUID, userAgent), customer)
 	if err != nil {
-		s.log.Error("failed to send LoginUser to mindbox", "error", err.Error())
-		return fmt.Errorf("failed to login user in mindbox: %w", err)
+		s.log.Error("failed to send UpdateSubscriptions to mindbox", "error", err.Error())
+		return fmt.Errorf("failed to update subscriptions in mindbox: %w", err)
 	}
 
+	s.saveCustomer(user.ID, resp)
 	return nil
 }
 
-func (s *authMindboxService) Send(platform, operation string, data any, deviceUUID string, userAgent string) error {
-	var auth, endpointId string
-
-	switch platform {
-	case "android":
-		auth = s.cfg.Mindbox.Android.Auth
-		endpointId = s.cfg.Mindbox.Android.EndpointID
-	case "ios":
-		auth = s.cfg.Mindbox.IOS.Auth
-		endpointId = s.cfg.Mindbox.IOS.EndpointID
-	case "web":
-		auth = s.cfg.Mindbox.Web.Auth
-		endpointId = s.cfg.Mindbox.Web.EndpointID
-	default:
-		return errors.New("unknown platform")
+func mindboxDevice(platform, deviceUUID, userAgent string) mindbox.Device {
+	return mindbox.Device{
+		Platform:  platform,
+		UUID:      deviceUUID,
+		UserAgent: userAgent,
 	}
+}
 
-	url := fmt.Sprintf("%s?endpointId=%s&operation=%s.%s", s.cfg.Mindbox.Url, endpointId, s.cfg.Mindbox.OperationPrefix, operation)
-	if deviceUUID != "" {
-		url = fmt.Sprintf("%s&deviceUUID=%s", url, deviceUUID)
-	}
+// customer describes user to Mindbox. Only the consent the user stated goes in
+// the subscriptions, so Mindbox keeps its own state for the rest.
+// withMindboxID adds the Mindbox customer id stored for the user.
+func (s *authMindboxService) customer(user *models.User, websiteID string, userMindBox *models.UserMindBox, withMindboxID bool) mindbox.Customer {
+	customer := mindbox.Customer{
+		IDs: mindbox.CustomerIDs{
+			WebsiteID: websiteID,
+		},
+		MobilePhone: user.Phone.String,
+	}
+
+	if userMindBox != nil {
+		if withMindboxID && userMindBox.MindBoxUserID.Valid && userMindBox.MindBoxUserID.String != "" {
+			// Mindbox ids are numbers; anything else was not stored from a
+			// Mindbox response and is left out.
+			if _, err := strconv.ParseInt(userMindBox.MindBoxUserID.String, 10, 64); err == nil {
+				customer.IDs.MindboxID = json.Number(userMindBox.MindBoxUserID.String)
+			} else {
+				s.log.Warn("Ignoring invalid mindbox id", "user_id", user.ID, "mindbox_id", userMindBox.MindBoxUserID.String)
+			}
+		}
 
-	jsonData, err := json.Marshal(data)
-	if err != nil {
-		return fmt.Errorf("failed to marshal request data: %w", err)
+		for _, channel := range models.ConsentChannels {
+			if consent := userMindBox.Consent(channel); consent.Valid {
+				customer.Subscriptions = append(customer.Subscriptions, mindbox.Subscription{
+					PointOfContact: mindboxPointsOfContact[channel],
+					IsSubscribed:   consent.Bool,
+				})
+			}
+		}
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+	if user.FirstName.Valid {
+		customer.FirstName = user.FirstName.String
 	}
-
-	req.Header.Set("Content-Type", "application/json; charset=utf-8")
-	req.Header.Set("Authorization", auth)
-	if userAgent != "" {
-		req.Header.Set("User-Agent", userAgent)
+	if user.LastName.Valid {
+		customer.LastName = user.LastName.String
 	}
-	client := &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		},
+	if user.Email.Valid {
+		customer.Email = user.Email.String
 	}
 
-	resp, err := client.Do(req)
+	return customer
+}
+
+// userMindBox returns the user's user_mind_box record, creating it when there
+// is none. Failures are only logged and return nil: the operation is still
+// worth sending without it.
+func (s *authMindboxService) userMindBox(userID int64) *models.UserMindBox {
+	userMindBox, err := s.userMindBoxRepo.FindByUserID(userID)
 	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
+		s.log.Warn("Failed to check user in user_mind_box", "user_id", userID, "error", err)
+		return nil
+	}
+	if userMindBox != nil {
+		return userMindBox
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	userMindBox, err = s.userMindBoxRepo.Create(userID)
 	if err != nil {
-		return fmt.Errorf("failed to read response body: %w", err)
+		s.log.Warn("Failed to create user in user_mind_box", "user_id", userID, "error", err)
+		return nil
 	}
+	s.log.Debug("Created user record in user_mind_box", "user_id", userID)
+	return userMindBox
+}
 
-	if resp.StatusCode != http.StatusOK {
-		s.log.Error("Mindbox API error response",
-			"status", resp.Status,
-			"body", string(body))
-	} else {
-		s.log.Debug("Mindbox API response", "status", resp.Status)
+// saveCustomer stores the customer id and processing status Mindbox answered
+// with. The operation already succeeded, so a failure here is only logged.
+func (s *authMindboxService) saveCustomer(userID int64, resp *mindbox.Response) {
+	if resp.Customer == nil {
+		return
 	}
+	mindboxID := resp.Customer.IDs.MindboxID.String()
+	status := resp.Customer.ProcessingStatus
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, string(body))
+	if err := s.userMindBoxRepo.UpdateMindboxCustomer(userID, mindboxID, status); err != nil {
+		s.log.Warn("Failed to save mindbox customer", "user_id", userID, "mindbox_id", mindboxID, "error", err)
+		return
 	}
-
-	return nil
+	s.log.Debug("Saved mindbox customer", "user_id", userID, "mindbox_id", mindboxID, "processing_status", status)
 }
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"sso/internal/logger"
+	"sso/internal/models"
+	"sso/pkg/mindbox"
+	"sso/pkg/mindbox/mindboxtest"
+)
+
+// fakeUserMindBoxRepo keeps user_mind_box rows in memory.
+type fakeUserMindBoxRepo struct {
+	rows map[int64]*models.UserMindBox
+}
+
+func newFakeUserMindBoxRepo() *fakeUserMindBoxRepo {
+	return &fakeUserMindBoxRepo{rows: make(map[int64]*models.UserMindBox)}
+}
+
+func (r *fakeUserMindBoxRepo) FindByUserID(userID int64) (*models.UserMindBox, error) {
+	return r.rows[userID], nil
+}
+
+func (r *fakeUserMindBoxRepo) Create(userID int64) (*models.UserMindBox, error) {
+	row := &models.UserMindBox{UserID: sql.NullInt64{Int64: userID, Valid: true}}
+	r.rows[userID] = row
+	return row, nil
+}
+
+func (r *fakeUserMindBoxRepo) UpdateMindboxCustomer(userID int64, mindboxID, status string) error {
+	row := r.rows[userID]
+	row.MindBoxUserID = sql.NullString{String: mindboxID, Valid: true}
+	row.MindBoxStatus = sql.NullString{String: status, Valid: true}
+	return nil
+}
+
+func (r *fakeUserMindBoxRepo) UpdateConsent(userID int64, changes []models.UserConsent) error {
+	row := r.rows[userID]
+	for _, c := range changes {
+		granted := sql.NullBool{Bool: c.Granted, Valid: true}
+		switch c.Channel {
+		case models.ConsentEmail:
+			row.ConsentEmail = granted
+		case models.ConsentSMS:
+			row.ConsentSMS = granted
+		case models.ConsentWebpush:
+			row.ConsentWebpush = granted
+		}
+	}
+	return nil
+}
+
+func newTestMindboxService(t *testing.T) (*authMindboxService, *fakeUserMindBoxRepo, *mindboxtest.Server) {
+	t.Helper()
+
+	srv := mindboxtest.NewServer()
+	t.Cleanup(srv.Close)
+
+	log, err := logger.NewLogger(logger.Options{Format: logger.FormatJSON, Level: slog.LevelError})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo := newFakeUserMindBoxRepo()
+	client := mindbox.New(srv.Config("Test", "ios"), srv.Client())
+	s := NewAuthMindboxService(log, nil, repo, client).(*authMindboxService)
+	return s, repo, srv
+}
+
+func testUser() *models.User {
+	return &models.User{
+		ID:    7,
+		Phone: sql.NullString{String: "79001234567", Valid: true},
+		Email: sql.NullString{String: "user@example.com", Valid: true},
+	}
+}
+
+func TestAuthMindboxService_RegisterLoginUpdate(t *testing.T) {
+	s, repo, srv := newTestMindboxService(t)
+	user := testUser()
+
+	if err := s.RegisterUser(user, "ios", "site-7", "device-1", "app/1.0"); err != nil {
+		t.Fatalf("RegisterUser: %v", err)
+	}
+	row := repo.rows[user.ID]
+	if row == nil || !row.MindBoxUserID.Valid || row.MindBoxStatus.String != "Created" {
+		t.Fatalf("customer not saved after register: %+v", row)
+	}
+	mindboxID := row.MindBoxUserID.String
+
+	if err := s.LoginUser(user, "ios", "site-7", "device-1", "app/1.0"); err != nil {
+		t.Fatalf("LoginUser: %v", err)
+	}
+	if row.MindBoxUserID.String != mindboxID || row.MindBoxStatus.String != "Found" {
+		t.Fatalf("login did not match the registered customer: %+v", row)
+	}
+
+	if err := repo.UpdateConsent(user.ID, []models.UserConsent{{Channel: models.ConsentSMS, Granted: false}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateSubscriptions(user, "ios", "site-7", "device-1", "app/1.0"); err != nil {
+		t.Fatalf("UpdateSubscriptions: %v", err)
+	}
+
+	ops := srv.Operations()
+	wantNames := []string{"Test.RegisterCustomer", "Test.AuthorizeCustomer", "Test.EditCustomer"}
+	if len(ops) != len(wantNames) {
+		t.Fatalf("got %d operations, want %d", len(ops), len(wantNames))
+	}
+	for i, op := range ops {
+		if op.Name != wantNames[i] {
+			t.Errorf("operation %d is %s, want %s", i, op.Name, wantNames[i])
+		}
+		if op.EndpointID != "endpoint-ios" || op.DeviceUUID != "device-1" || op.UserAgent != "app/1.0" {
+			t.Errorf("operation %d sent for the wrong device: %+v", i, op)
+		}
+		if op.Customer.MobilePhone != "79001234567" || op.Customer.IDs.WebsiteID != "site-7" {
+			t.Errorf("operation %d sent the wrong customer: %+v", i, op.Customer)
+		}
+	}
+
+	if ops[0].Customer.IDs.MindboxID != "" {
+		t.Errorf("register sent mindboxId %s", ops[0].Customer.IDs.MindboxID)
+	}
+	for _, op := range ops[1:] {
+		if op.Customer.IDs.MindboxID.String() != mindboxID {
+			t.Errorf("%s sent mindboxId %q, want %q", op.Name, op.Customer.IDs.MindboxID, mindboxID)
+		}
+	}
+
+	subs := ops[2].Customer.Subscriptions
+	if len(subs) != 1 || subs[0].PointOfContact != mindbox.PointOfContactSMS || subs[0].IsSubscribed {
+		t.Errorf("edit sent subscriptions %+v, want only SMS unsubscribed", subs)
+	}
+
+	if customers := srv.Customers(); len(customers) != 1 {
+		t.Errorf("server has %d customers, want 1", len(customers))
+	}
+}
+
+func TestAuthMindboxService_Errors(t *testing.T) {
+	s, _, srv := newTestMindboxService(t)
+	user := testUser()
+
+	srv.Stub("RegisterCustomer", http.StatusOK, mindbox.Response{
+		Status:             mindbox.StatusValidationError,
+		ValidationMessages: []mindbox.ValidationMessage{{Message: "invalid phone", Location: "/customer/mobilePhone"}},
+	})
+	srv.Stub("AuthorizeCustomer", http.StatusServiceUnavailable, "unavailable")
+
+	if err := s.RegisterUser(user, "ios", "", "", ""); !errors.Is(err, mindbox.ErrValidation) {
+		t.Errorf("validation error: got %v", err)
+	}
+	if err := s.LoginUser(user, "ios", "", "", ""); !errors.Is(err, mindbox.ErrTransient) {
+		t.Errorf("unavailable: got %v", err)
+	}
+
+	srv.RequireAuth("endpoint-ios", "wrong")
+	if err := s.LoginUser(user, "ios", "", "", ""); !errors.Is(err, mindbox.ErrAuth) {
+		t.Errorf("rejected key: got %v", err)
+	}
+
+	if err := s.LoginUser(user, "android", "", "", ""); !errors.Is(err, mindbox.ErrValidation) {
+		t.Errorf("unknown platform: got %v", err)
+	}
+}
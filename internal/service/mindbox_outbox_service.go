@@ -0,0 +1,214 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"sso/internal/config"
+	"sso/internal/logger"
+	"sso/internal/models"
+	"sso/internal/repository"
+	"sso/pkg/apperror"
+	"sso/pkg/mindbox"
+)
+
+// MindboxOutboxService sends the Mindbox operations queued in mindbox_outbox.
+// Nothing calls Mindbox from a login directly, so an outage or a restart only
+// delays operations instead of losing them.
+type MindboxOutboxService interface {
+	EnqueueLogin(userID int64, event MindboxEvent) error
+	EnqueueSubscriptions(userID int64, event MindboxEvent) error
+	Process() error
+	List(status string, userID int64, limit int) ([]models.MindboxOutboxEvent, error)
+	Replay(id int64) error
+}
+
+// MindboxEvent is the request context an operation is sent with. An empty
+// WebsiteID is replaced with the user id.
+type MindboxEvent struct {
+	Platform   string `json:"platform"`
+	WebsiteID  string `json:"website_id"`
+	DeviceUUID string `json:"device_uuid"`
+	Agent      string `json:"agent"`
+}
+
+// Encode returns the event as the payload stored in the outbox.
+func (e MindboxEvent) Encode() (string, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+type mindboxOutboxService struct {
+	outboxRepo repository.MindboxOutboxRepository
+	userRepo   repository.UserRepository
+	mindbox    AuthMindboxService
+	cfg        config.MindboxConfig
+	logger     *logger.Logger
+}
+
+func NewMindboxOutboxService(outboxRepo repository.MindboxOutboxRepository, userRepo repository.UserRepository, mindbox AuthMindboxService, cfg config.MindboxConfig, logger *logger.Logger) MindboxOutboxService {
+	return &mindboxOutboxService{
+		outboxRepo: outboxRepo,
+		userRepo:   userRepo,
+		mindbox:    mindbox,
+		cfg:        cfg,
+		logger:     logger,
+	}
+}
+
+func (s *mindboxOutboxService) EnqueueLogin(userID int64, event MindboxEvent) error {
+	return s.enqueue(userID, models.MindboxOperationLogin, event)
+}
+
+// EnqueueSubscriptions queues the user's consent, read when it is sent.
+func (s *mindboxOutboxService) EnqueueSubscriptions(userID int64, event MindboxEvent) error {
+	return s.enqueue(userID, models.MindboxOperationSubscriptions, event)
+}
+
+func (s *mindboxOutboxService) enqueue(userID int64, operation string, event MindboxEvent) error {
+	payload, err := event.Encode()
+	if err != nil {
+		return apperror.Internal(err, "error encoding mindbox event")
+	}
+	if _, err := s.outboxRepo.Enqueue(userID, operation, payload); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Process sends one batch of due operations. Each one is claimed first, so
+// several instances can run it at the same time.
+func (s *mindboxOutboxService) Process() error {
+	now := time.Now()
+	events, err := s.outboxRepo.FindDue(now, s.cfg.OutboxBatch)
+	if err != nil {
+		return err
+	}
+
+	for i := range events {
+		event := &events[i]
+
+		claimed, err := s.outboxRepo.Claim(event.ID, now, now.Add(s.cfg.OutboxLease))
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			continue
+		}
+
+		if err := s.send(event); err != nil {
+			s.fail(event, err)
+			continue
+		}
+		if err := s.outboxRepo.MarkSent(event.ID); err != nil {
+			s.logger.Error("Failed to mark mindbox event sent", "event_id", event.ID, "error", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *mindboxOutboxService) send(event *models.MindboxOutboxEvent) error {
+	var payload MindboxEvent
+	if err := json.Unmarshal([]byte(event.Payload), &payload); err != nil {
+		return fmt.Errorf("invalid payload: %w", err)
+	}
+
+	user, err := s.userRepo.FindByID(event.UserID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return fmt.Errorf("user %d not found", event.UserID)
+	}
+
+	websiteID := payload.WebsiteID
+	if websiteID == "" {
+		websiteID = strconv.FormatInt(user.ID, 10)
+	}
+
+	switch event.Operation {
+	case models.MindboxOperationRegister:
+		return s.mindbox.RegisterUser(user, payload.Platform, websiteID, payload.DeviceUUID, payload.Agent)
+	case models.MindboxOperationLogin:
+		return s.mindbox.LoginUser(user, payload.Platform, websiteID, payload.DeviceUUID, payload.Agent)
+	case models.MindboxOperationSubscriptions:
+		return s.mindbox.UpdateSubscriptions(user, payload.Platform, websiteID, payload.DeviceUUID, payload.Agent)
+	}
+	return fmt.Errorf("unknown operation %q", event.Operation)
+}
+
+// fail schedules the next attempt of event, or gives up on it once it has used
+// up its attempts or Mindbox rejected it as invalid, which retrying would not
+// change.
+func (s *mindboxOutboxService) fail(event *models.MindboxOutboxEvent, cause error) {
+	attempts := event.Attempts + 1
+
+	if attempts >= s.cfg.OutboxMaxAttempts || errors.Is(cause, mindbox.ErrValidation) {
+		s.logger.Error("Mindbox event is dead",
+			"event_id", event.ID,
+			"user_id", event.UserID,
+			"operation", event.Operation,
+			"attempts", attempts,
+			"error", cause)
+		if err := s.outboxRepo.MarkDead(event.ID, attempts, cause.Error()); err != nil {
+			s.logger.Error("Failed to mark mindbox event dead", "event_id", event.ID, "error", err)
+		}
+		return
+	}
+
+	backoff := s.cfg.OutboxBackoff
+	for i := 1; i < attempts && backoff < s.cfg.OutboxBackoffMax; i++ {
+		backoff *= 2
+	}
+	backoff = min(backoff, s.cfg.OutboxBackoffMax)
+
+	s.logger.Warn("Mindbox event failed, will retry",
+		"event_id", event.ID,
+		"user_id", event.UserID,
+		"operation", event.Operation,
+		"attempts", attempts,
+		"retry_in", backoff,
+		"error", cause)
+	if err := s.outboxRepo.MarkFailed(event.ID, attempts, cause.Error(), time.Now().Add(backoff)); err != nil {
+		s.logger.Error("Failed to reschedule mindbox event", "event_id", event.ID, "error", err)
+	}
+}
+
+func (s *mindboxOutboxService) List(status string, userID int64, limit int) ([]models.MindboxOutboxEvent, error) {
+	switch status {
+	case "":
+		status = models.MindboxOutboxDead
+	case models.MindboxOutboxPending, models.MindboxOutboxSent, models.MindboxOutboxDead:
+	default:
+		return nil, apperror.ErrInvalidRequest.WithMessage("status must be pending, sent or dead")
+	}
+	return s.outboxRepo.FindByStatus(status, userID, limit)
+}
+
+// Replay queues a dead event again with a fresh attempt budget.
+func (s *mindboxOutboxService) Replay(id int64) error {
+	replayed, err := s.outboxRepo.Replay(id)
+	if err != nil {
+		return err
+	}
+	if replayed {
+		s.logger.Info("Mindbox event replayed", "event_id", id)
+		return nil
+	}
+
+	event, err := s.outboxRepo.FindByID(id)
+	if err != nil {
+		return err
+	}
+	if event == nil {
+		return apperror.ErrNotFound.WithMessage("mindbox event not found")
+	}
+	return apperror.ErrInvalidRequest.WithMessage(fmt.Sprintf("mindbox event is %s, only dead events can be replayed", event.Status))
+}
@@ -0,0 +1,597 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"sso/internal/config"
+	"sso/internal/logger"
+	"sso/internal/models"
+	"sso/internal/repository"
+	"sso/pkg/apperror"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	ScopeOpenID        = "openid"
+	ScopeProfile       = "profile"
+	ScopePhone         = "phone"
+	ScopeEmail         = "email"
+	ScopeOfflineAccess = "offline_access"
+
+	GrantAuthorizationCode = "authorization_code"
+	GrantRefreshToken      = "refresh_token"
+
+	pkceMethodS256 = "S256"
+)
+
+var supportedScopes = []string{ScopeOpenID, ScopeProfile, ScopePhone, ScopeEmail, ScopeOfflineAccess}
+
+// OAuthError is an RFC 6749 error. Code is one of the registered error codes
+// and is returned to clients as is.
+type OAuthError struct {
+	Code        string
+	Description string
+	Status      int
+}
+
+func (e *OAuthError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Description)
+}
+
+func oauthError(code, description string) *OAuthError {
+	status := http.StatusBadRequest
+	switch code {
+	case "invalid_client":
+		status = http.StatusUnauthorized
+	case "access_denied":
+		status = http.StatusForbidden
+	case "server_error":
+		status = http.StatusInternalServerError
+	}
+	return &OAuthError{Code: code, Description: description, Status: status}
+}
+
+type AuthorizationRequest struct {
+	ID                  string `json:"id"`
+	ClientID            string `json:"client_id"`
+	RedirectURI         string `json:"redirect_uri"`
+	ResponseType        string `json:"response_type"`
+	Scope               string `json:"scope"`
+	State               string `json:"state,omitempty"`
+	Nonce               string `json:"nonce,omitempty"`
+	CodeChallenge       string `json:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method"`
+}
+
+type authorizationCode struct {
+	ClientID      string `json:"client_id"`
+	RedirectURI   string `json:"redirect_uri"`
+	Scope         string `json:"scope"`
+	Nonce         string `json:"nonce,omitempty"`
+	CodeChallenge string `json:"code_challenge"`
+	UserID        int64  `json:"user_id"`
+	AuthTime      int64  `json:"auth_time"`
+
+	// The session is recorded against the device that logged in, not the
+	// client backend that redeems the code.
+	Platform   string `json:"platform,omitempty"`
+	DeviceUUID string `json:"device_uuid,omitempty"`
+	Agent      string `json:"agent,omitempty"`
+	IP         string `json:"ip,omitempty"`
+}
+
+type TokenRequest struct {
+	GrantType    string
+	Code         string
+	RedirectURI  string
+	ClientID     string
+	ClientSecret string
+	CodeVerifier string
+	RefreshToken string
+	Agent        string
+	IP           string
+}
+
+type OIDCTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+// UserClaims are the standard OIDC claims released for a user, filtered by
+// the granted scopes.
+type UserClaims struct {
+	PhoneNumber         string `json:"phone_number,omitempty"`
+	PhoneNumberVerified bool   `json:"phone_number_verified,omitempty"`
+	PreferredUsername   string `json:"preferred_username,omitempty"`
+	GivenName           string `json:"given_name,omitempty"`
+	FamilyName          string `json:"family_name,omitempty"`
+	Locale              string `json:"locale,omitempty"`
+	UpdatedAt           int64  `json:"updated_at,omitempty"`
+	Email               string `json:"email,omitempty"`
+}
+
+type IDTokenClaims struct {
+	UserClaims
+	Nonce    string `json:"nonce,omitempty"`
+	AuthTime int64  `json:"auth_time,omitempty"`
+	AZP      string `json:"azp,omitempty"`
+	jwt.RegisteredClaims
+}
+
+type UserInfo struct {
+	Sub string `json:"sub"`
+	UserClaims
+}
+
+type OpenIDConfiguration struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserinfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+type OIDCService interface {
+	Discovery() OpenIDConfiguration
+	ValidateRedirect(clientID, redirectURI string) error
+	Authorize(req *AuthorizationRequest) error
+	CompleteAuthorization(ctx context.Context, requestID, verificationID, phone, code, deviceUUID, agent, ip string, consent Consent) (string, error)
+	Exchange(ctx context.Context, req *TokenRequest) (*OIDCTokenResponse, error)
+	UserInfo(accessToken string) (*UserInfo, error)
+}
+
+type oidcService struct {
+	ssoService    SSOService
+	clientService ClientService
+	jwtService    JWTService
+	userRepo      repository.UserRepository
+	cache         CacheService
+	cfg           config.OIDCConfig
+	signingAlg    string
+	logger        *logger.Logger
+}
+
+func NewOIDCService(
+	ssoService SSOService,
+	clientService ClientService,
+	jwtService JWTService,
+	userRepo repository.UserRepository,
+	cache CacheService,
+	cfg *config.Config,
+	logger *logger.Logger,
+) OIDCService {
+	return &oidcService{
+		ssoService:    ssoService,
+		clientService: clientService,
+		jwtService:    jwtService,
+		userRepo:      userRepo,
+		cache:         cache,
+		cfg:           cfg.OIDC,
+		signingAlg:    cfg.JWT.SigningAlg,
+		logger:        logger,
+	}
+}
+
+func (s *oidcService) Discovery() OpenIDConfiguration {
+	issuer := strings.TrimSuffix(s.cfg.Issuer, "/")
+	return OpenIDConfiguration{
+		Issuer:                            issuer,
+		AuthorizationEndpoint:             issuer + "/authorize",
+		TokenEndpoint:                     issuer + "/token",
+		UserinfoEndpoint:                  issuer + "/userinfo",
+		JWKSURI:                           issuer + "/.well-known/jwks.json",
+		IntrospectionEndpoint:             issuer + "/introspect",
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{GrantAuthorizationCode, GrantRefreshToken},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{s.signingAlg},
+		ScopesSupported:                   supportedScopes,
+		TokenEndpointAuthMethodsSupported: []string{"none", "client_secret_basic", "client_secret_post"},
+		CodeChallengeMethodsSupported:     []string{pkceMethodS256},
+		ClaimsSupported: []string{
+			"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "azp",
+			"phone_number", "phone_number_verified", "preferred_username",
+			"given_name", "family_name", "locale", "updated_at", "email",
+		},
+	}
+}
+
+// ValidateRedirect decides whether errors may be reported by redirecting back
+// to the client. Until it passes, errors must be shown to the user instead.
+// The redirect URI must exactly match one registered for the client.
+func (s *oidcService) ValidateRedirect(clientID, redirectURI string) error {
+	client, err := s.activeClient(clientID)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(client.RedirectURIList(), redirectURI) {
+		return oauthError("invalid_request", "redirect_uri is not registered for this client")
+	}
+	return nil
+}
+
+func (s *oidcService) activeClient(clientID string) (*models.Client, error) {
+	if clientID == "" {
+		return nil, oauthError("invalid_request", "client_id is required")
+	}
+
+	client, err := s.clientService.Get(clientID)
+	if errors.Is(err, apperror.ErrClientNotFound) {
+		return nil, oauthError("invalid_request", "unknown client")
+	}
+	if err != nil {
+		s.logger.Error("Failed to load client", "client_id", clientID, "error", err)
+		return nil, oauthError("server_error", "failed to load client")
+	}
+	if !client.IsActive {
+		return nil, oauthError("invalid_request", "unknown client")
+	}
+	return client, nil
+}
+
+// Authorize validates an authorization request and stores it until the user
+// finishes the SMS login. req.ID is set to the id the login page resumes with.
+func (s *oidcService) Authorize(req *AuthorizationRequest) error {
+	if req.ResponseType != "code" {
+		return oauthError("unsupported_response_type", "only the authorization code flow is supported")
+	}
+
+	client, err := s.activeClient(req.ClientID)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(client.GrantTypeList(), GrantAuthorizationCode) {
+		return oauthError("unauthorized_client", "client is not allowed to use the authorization code flow")
+	}
+
+	scopes := strings.Fields(req.Scope)
+	if !slices.Contains(scopes, ScopeOpenID) {
+		return oauthError("invalid_scope", "the openid scope is required")
+	}
+	allowed := client.ScopeList()
+	granted := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !slices.Contains(supportedScopes, scope) || slices.Contains(granted, scope) {
+			continue
+		}
+		if !slices.Contains(allowed, scope) {
+			return oauthError("invalid_scope", fmt.Sprintf("scope %s is not allowed for this client", scope))
+		}
+		granted = append(granted, scope)
+	}
+	req.Scope = strings.Join(granted, " ")
+
+	if req.CodeChallenge == "" {
+		return oauthError("invalid_request", "code_challenge is required")
+	}
+	if req.CodeChallengeMethod != pkceMethodS256 {
+		return oauthError("invalid_request", "code_challenge_method must be S256")
+	}
+
+	id, err := generateOpaqueToken()
+	if err != nil {
+		return oauthError("server_error", "failed to create authorization request")
+	}
+	req.ID = id
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return oauthError("server_error", "failed to create authorization request")
+	}
+	if err := s.cache.SaveAuthRequest(context.Background(), id, data, s.cfg.AuthRequestTTL); err != nil {
+		s.logger.Error("Failed to save authorization request", "error", err)
+		return oauthError("server_error", "failed to create authorization request")
+	}
+
+	return nil
+}
+
+// CompleteAuthorization authenticates the user with the code of verification
+// session verificationID and returns the client redirect URL carrying the
+// authorization code. Authentication errors are returned unchanged so the
+// login page can show them.
+func (s *oidcService) CompleteAuthorization(ctx context.Context, requestID, verificationID, phone, code, deviceUUID, agent, ip string, consent Consent) (string, error) {
+	data, err := s.cache.GetAuthRequest(ctx, requestID)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Failed to load authorization request", "error", err)
+		return "", oauthError("server_error", "failed to load authorization request")
+	}
+	if data == nil {
+		return "", oauthError("invalid_request", "authorization request expired or not found")
+	}
+
+	var req AuthorizationRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return "", oauthError("server_error", "failed to load authorization request")
+	}
+
+	user, platform, err := s.ssoService.Authenticate(ctx, verificationID, phone, code, deviceUUID, agent, ip, "", consent)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.cache.DeleteAuthRequest(ctx, requestID); err != nil {
+		s.logger.WarnContext(ctx, "Failed to delete authorization request", "error", err)
+	}
+
+	authCode, err := generateOpaqueToken()
+	if err != nil {
+		return "", oauthError("server_error", "failed to issue authorization code")
+	}
+
+	codeData, err := json.Marshal(authorizationCode{
+		ClientID:      req.ClientID,
+		RedirectURI:   req.RedirectURI,
+		Scope:         req.Scope,
+		Nonce:         req.Nonce,
+		CodeChallenge: req.CodeChallenge,
+		UserID:        user.ID,
+		AuthTime:      time.Now().Unix(),
+		Platform:      platform,
+		DeviceUUID:    deviceUUID,
+		Agent:         agent,
+		IP:            ip,
+	})
+	if err != nil {
+		return "", oauthError("server_error", "failed to issue authorization code")
+	}
+	if err := s.cache.SaveAuthCode(ctx, authCode, codeData, s.cfg.AuthCodeTTL); err != nil {
+		s.logger.ErrorContext(ctx, "Failed to save authorization code", "error", err)
+		return "", oauthError("server_error", "failed to issue authorization code")
+	}
+
+	params := url.Values{}
+	params.Set("code", authCode)
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	params.Set("iss", s.Discovery().Issuer)
+
+	return AppendQuery(req.RedirectURI, params), nil
+}
+
+func (s *oidcService) Exchange(ctx context.Context, req *TokenRequest) (*OIDCTokenResponse, error) {
+	if req.GrantType != GrantAuthorizationCode && req.GrantType != GrantRefreshToken {
+		return nil, oauthError("unsupported_grant_type", "grant_type must be authorization_code or refresh_token")
+	}
+
+	client, err := s.clientService.Authenticate(req.ClientID, req.ClientSecret)
+	if errors.Is(err, apperror.ErrInvalidClient) {
+		s.logger.WarnContext(ctx, "Client authentication failed", "client_id", req.ClientID, "error", err)
+		return nil, oauthError("invalid_client", "client authentication failed")
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Failed to authenticate client", "client_id", req.ClientID, "error", err)
+		return nil, oauthError("server_error", "failed to authenticate client")
+	}
+	if !slices.Contains(client.GrantTypeList(), req.GrantType) {
+		return nil, oauthError("unauthorized_client", "client is not allowed to use this grant type")
+	}
+
+	switch req.GrantType {
+	case GrantAuthorizationCode:
+		return s.exchangeCode(ctx, client, req)
+	case GrantRefreshToken:
+		return s.exchangeRefreshToken(ctx, req)
+	default:
+		return nil, oauthError("unsupported_grant_type", "grant_type must be authorization_code or refresh_token")
+	}
+}
+
+func (s *oidcService) exchangeCode(ctx context.Context, client *models.Client, req *TokenRequest) (*OIDCTokenResponse, error) {
+	if req.Code == "" {
+		return nil, oauthError("invalid_request", "code is required")
+	}
+
+	data, err := s.cache.ConsumeAuthCode(ctx, req.Code)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Failed to consume authorization code", "error", err)
+		return nil, oauthError("server_error", "failed to redeem authorization code")
+	}
+	if data == nil {
+		return nil, oauthError("invalid_grant", "authorization code is invalid, expired or already used")
+	}
+
+	var code authorizationCode
+	if err := json.Unmarshal(data, &code); err != nil {
+		return nil, oauthError("server_error", "failed to redeem authorization code")
+	}
+
+	if code.ClientID != req.ClientID {
+		return nil, oauthError("invalid_grant", "authorization code was issued to another client")
+	}
+	if code.RedirectURI != req.RedirectURI {
+		return nil, oauthError("invalid_grant", "redirect_uri does not match the authorization request")
+	}
+	if !verifyPKCE(req.CodeVerifier, code.CodeChallenge) {
+		return nil, oauthError("invalid_grant", "code_verifier does not match code_challenge")
+	}
+
+	user, err := s.userRepo.FindByID(code.UserID)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Failed to load user for token exchange", "user_id", code.UserID, "error", err)
+		return nil, oauthError("server_error", "failed to load user")
+	}
+	if user == nil {
+		return nil, oauthError("invalid_grant", "user no longer exists")
+	}
+
+	// Only clients with the refresh_token grant can use a refresh token, so
+	// offline_access is not granted to the others.
+	canRefresh := slices.Contains(client.GrantTypeList(), GrantRefreshToken)
+	scope := code.Scope
+	if !canRefresh {
+		scope = strings.Join(slices.DeleteFunc(strings.Fields(scope), func(s string) bool {
+			return s == ScopeOfflineAccess
+		}), " ")
+	}
+
+	tokens, err := s.ssoService.IssueTokens(user, code.ClientID, scope, DeviceInfo{
+		Platform:   code.Platform,
+		DeviceUUID: code.DeviceUUID,
+		Agent:      code.Agent,
+		IP:         code.IP,
+	})
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Failed to issue tokens", "user_id", user.ID, "error", err)
+		return nil, oauthError("server_error", "failed to issue tokens")
+	}
+
+	now := time.Now()
+	idToken, err := s.jwtService.SignClaims(&IDTokenClaims{
+		UserClaims: userClaims(user, scope),
+		Nonce:      code.Nonce,
+		AuthTime:   code.AuthTime,
+		AZP:        code.ClientID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.Discovery().Issuer,
+			Subject:   strconv.FormatInt(user.ID, 10),
+			Audience:  jwt.ClaimStrings{code.ClientID},
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.cfg.IDTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	})
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Failed to sign ID token", "user_id", user.ID, "error", err)
+		return nil, oauthError("server_error", "failed to issue tokens")
+	}
+
+	resp := &OIDCTokenResponse{
+		AccessToken: tokens.AccessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   tokens.ExpiresIn,
+		IDToken:     idToken,
+		Scope:       tokens.Scope,
+	}
+	// The refresh token is still stored, as it anchors the session, but is only
+	// handed out to clients allowed to refresh.
+	if canRefresh {
+		resp.RefreshToken = tokens.RefreshToken
+	}
+	return resp, nil
+}
+
+func (s *oidcService) exchangeRefreshToken(ctx context.Context, req *TokenRequest) (*OIDCTokenResponse, error) {
+	if req.RefreshToken == "" {
+		return nil, oauthError("invalid_request", "refresh_token is required")
+	}
+
+	tokens, err := s.ssoService.Refresh(ctx, req.RefreshToken, req.ClientID, req.Agent, req.IP)
+	if err != nil {
+		s.logger.WarnContext(ctx, "Refresh token grant failed", "client_id", req.ClientID, "error", err)
+		return nil, oauthError("invalid_grant", "refresh token is invalid or expired")
+	}
+
+	return &OIDCTokenResponse{
+		AccessToken:  tokens.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    tokens.ExpiresIn,
+		RefreshToken: tokens.RefreshToken,
+		Scope:        tokens.Scope,
+	}, nil
+}
+
+func (s *oidcService) UserInfo(accessToken string) (*UserInfo, error) {
+	token, err := s.jwtService.ValidateToken(accessToken)
+	if err != nil {
+		return nil, oauthError("invalid_token", "access token is invalid or expired")
+	}
+
+	claims, ok := token.Claims.(*Claims)
+	if !ok {
+		return nil, oauthError("invalid_token", "access token is invalid or expired")
+	}
+
+	user, err := s.userRepo.FindByID(claims.UserID)
+	if err != nil {
+		s.logger.Error("Failed to load user for userinfo", "user_id", claims.UserID, "error", err)
+		return nil, oauthError("server_error", "failed to load user")
+	}
+	if user == nil {
+		return nil, oauthError("invalid_token", "user no longer exists")
+	}
+
+	// First-party tokens from /login carry no scope and see every claim.
+	scope := claims.Scope
+	if claims.ClientID == "" {
+		scope = strings.Join(supportedScopes, " ")
+	}
+
+	return &UserInfo{
+		Sub:        strconv.FormatInt(user.ID, 10),
+		UserClaims: userClaims(user, scope),
+	}, nil
+}
+
+func userClaims(user *models.User, scope string) UserClaims {
+	scopes := strings.Fields(scope)
+	var claims UserClaims
+
+	if slices.Contains(scopes, ScopePhone) && user.Phone.Valid {
+		claims.PhoneNumber = "+" + strings.TrimPrefix(user.Phone.String, "+")
+		claims.PhoneNumberVerified = true
+	}
+	if slices.Contains(scopes, ScopeProfile) {
+		claims.PreferredUsername = user.Username
+		claims.GivenName = user.FirstName.String
+		claims.FamilyName = user.LastName.String
+		claims.Locale = user.Lang
+		claims.UpdatedAt = user.UpdatedAt
+	}
+	if slices.Contains(scopes, ScopeEmail) {
+		claims.Email = user.Email.String
+	}
+
+	return claims
+}
+
+func verifyPKCE(verifier, challenge string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+// AppendQuery adds params to rawURL, keeping any query it already has.
+func AppendQuery(rawURL string, params url.Values) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	query := u.Query()
+	for key, values := range params {
+		for _, value := range values {
+			query.Add(key, value)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"sso/pkg/apperror"
+)
+
+func phoneSubject(phone string) string {
+	return "phone:" + phone
+}
+
+func ipSubject(ip string) string {
+	return "ip:" + ip
+}
+
+// checkLockout rejects phones and IPs that are locked out after too many wrong
+// codes. An empty ip only checks the phone.
+func (s *SSOAuthService) checkLockout(ctx context.Context, phone, ip string) error {
+	subjects := []string{phoneSubject(phone)}
+	if ip != "" {
+		subjects = append(subjects, ipSubject(ip))
+	}
+
+	for _, subject := range subjects {
+		remaining, err := s.CodeCache.GetLockout(ctx, subject)
+		if err != nil {
+			return apperror.Internal(err, "error checking lockout")
+		}
+		if remaining > 0 {
+			return tooManyAttempts(remaining)
+		}
+	}
+	return nil
+}
+
+// registerFailedAttempt counts a wrong code against the phone and the IP. Once
+// either reaches its limit the stored code is invalidated and that subject is
+// locked out; the returned error tells the client which case it hit.
+func (s *SSOAuthService) registerFailedAttempt(ctx context.Context, phone, ip string) error {
+	limits := []struct {
+		subject string
+		max     int
+	}{
+		{phoneSubject(phone), s.OTP.MaxAttempts},
+	}
+	if ip != "" {
+		limits = append(limits, struct {
+			subject string
+			max     int
+		}{ipSubject(ip), s.OTP.MaxAttemptsPerIP})
+	}
+
+	var lockedFor time.Duration
+	for _, limit := range limits {
+		if limit.max <= 0 {
+			continue
+		}
+
+		count, err := s.CodeCache.IncrementAttempts(ctx, limit.subject, s.OTP.AttemptWindow)
+		if err != nil {
+			return apperror.Internal(err, "error counting failed attempt")
+		}
+		if count < int64(limit.max) {
+			continue
+		}
+
+		duration, err := s.lockOut(ctx, limit.subject)
+		if err != nil {
+			return err
+		}
+		lockedFor = max(lockedFor, duration)
+	}
+
+	if lockedFor == 0 {
+		return apperror.ErrInvalidCode
+	}
+
+	if err := s.CodeCache.DeletePhoneVerification(ctx, phone); err != nil {
+		s.Logger.WarnContext(ctx, "Failed to invalidate verification code", "error", err)
+	}
+	return tooManyAttempts(lockedFor)
+}
+
+// lockOut locks subject for LockoutBase, doubling with every lockout that
+// happens within LockoutMemory of the previous one, up to LockoutMax.
+func (s *SSOAuthService) lockOut(ctx context.Context, subject string) (time.Duration, error) {
+	level, err := s.CodeCache.IncrementLockoutLevel(ctx, subject, s.OTP.LockoutMemory)
+	if err != nil {
+		return 0, apperror.Internal(err, "error escalating lockout")
+	}
+
+	duration := s.OTP.LockoutBase
+	for i := int64(1); i < level && duration < s.OTP.LockoutMax; i++ {
+		duration *= 2
+	}
+	duration = min(duration, s.OTP.LockoutMax)
+
+	if err := s.CodeCache.SetLockout(ctx, subject, duration); err != nil {
+		return 0, apperror.Internal(err, "error setting lockout")
+	}
+	if err := s.CodeCache.ResetAttempts(ctx, subject); err != nil {
+		s.Logger.WarnContext(ctx, "Failed to reset attempt counter", "subject", subject, "error", err)
+	}
+
+	s.Logger.WarnContext(ctx, "Verification locked out after too many wrong codes",
+		"subject", subject,
+		"level", level,
+		"duration", duration)
+	return duration, nil
+}
+
+// clearFailedAttempts forgets the phone's failures after a successful login.
+// The IP counter is kept: one address guessing for many phones stays limited.
+func (s *SSOAuthService) clearFailedAttempts(ctx context.Context, phone string) {
+	subject := phoneSubject(phone)
+	if err := s.CodeCache.ResetAttempts(ctx, subject); err != nil {
+		s.Logger.WarnContext(ctx, "Failed to reset attempt counter", "subject", subject, "error", err)
+	}
+	if err := s.CodeCache.ResetLockoutLevel(ctx, subject); err != nil {
+		s.Logger.WarnContext(ctx, "Failed to reset lockout level", "subject", subject, "error", err)
+	}
+}
+
+func tooManyAttempts(retryAfter time.Duration) *apperror.Error {
+	minutes := int(math.Ceil(retryAfter.Minutes()))
+	return apperror.ErrTooManyAttempts.
+		WithMessage(fmt.Sprintf("too many attempts, try again in %d minutes", minutes)).
+		WithRetryAfter(retryAfter)
+}
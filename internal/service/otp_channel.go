@@ -0,0 +1,108 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"sso/internal/config"
+	"sso/internal/logger"
+	"sso/pkg/apperror"
+	"sso/pkg/httpclient"
+)
+
+const (
+	ChannelSMS       = "sms"
+	ChannelVoice     = "voice"
+	ChannelFlashCall = "flashcall"
+	ChannelWhatsApp  = "whatsapp"
+	ChannelTelegram  = "telegram"
+)
+
+// OTPMessage carries what a channel may need besides the phone and the code.
+// Lang is always one of the languages the templates support.
+type OTPMessage struct {
+	Lang      string
+	Platform  string
+	Brand     string
+	Signature string
+}
+
+// OTPChannel delivers a verification code to a phone.
+type OTPChannel interface {
+	Name() string
+	Send(phone, code string, msg OTPMessage) error
+}
+
+// codeChoosingChannel is implemented by channels whose provider picks the
+// code itself, such as flash calls where the code is part of the caller ID.
+// They are sent synchronously and return the code that was delivered.
+type codeChoosingChannel interface {
+	OTPChannel
+	SendChoosingCode(phone string, msg OTPMessage) (string, error)
+}
+
+type OTPChannelRegistry interface {
+	Get(name string) (OTPChannel, error)
+	Names() []string
+}
+
+type otpChannelRegistry struct {
+	channels map[string]OTPChannel
+	names    []string
+}
+
+func NewOTPChannelRegistry(cfg config.ChannelConfig, smsCfg config.SMSConfig, smscClient *httpclient.Client, templates OTPTemplates, smsService SMSService, logger *logger.Logger) (OTPChannelRegistry, error) {
+	r := &otpChannelRegistry{channels: make(map[string]OTPChannel)}
+
+	for _, name := range cleanProviderNames(cfg.Enabled) {
+		if _, ok := r.channels[name]; ok {
+			continue
+		}
+		channel, err := newOTPChannel(name, cfg, smsCfg, smscClient, templates, smsService, logger)
+		if err != nil {
+			return nil, err
+		}
+		r.channels[name] = channel
+		r.names = append(r.names, name)
+	}
+	if len(r.names) == 0 {
+		return nil, fmt.Errorf("OTP_CHANNELS must enable at least one channel")
+	}
+
+	return r, nil
+}
+
+func newOTPChannel(name string, cfg config.ChannelConfig, smsCfg config.SMSConfig, smscClient *httpclient.Client, templates OTPTemplates, smsService SMSService, logger *logger.Logger) (OTPChannel, error) {
+	switch name {
+	case ChannelSMS:
+		return newSMSChannel(templates, smsService), nil
+	case ChannelVoice:
+		return newVoiceChannel(templates, smsCfg, smscClient, logger)
+	case ChannelFlashCall:
+		return newFlashCallChannel(smsCfg, smscClient, logger)
+	case ChannelWhatsApp:
+		return newWhatsAppChannel(cfg, smsCfg.Timeout)
+	case ChannelTelegram:
+		return newTelegramChannel(cfg, smsCfg.Timeout)
+	}
+	return nil, fmt.Errorf("unknown OTP channel %q", name)
+}
+
+// Get returns the named channel; an empty name selects the first enabled one.
+func (r *otpChannelRegistry) Get(name string) (OTPChannel, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		name = r.names[0]
+	}
+
+	channel, ok := r.channels[name]
+	if !ok {
+		return nil, apperror.ErrUnsupportedChannel.WithMessage(
+			fmt.Sprintf("unsupported verification channel %q, available: %s", name, strings.Join(r.names, ", ")))
+	}
+	return channel, nil
+}
+
+func (r *otpChannelRegistry) Names() []string {
+	return r.names
+}
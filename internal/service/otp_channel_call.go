@@ -0,0 +1,73 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"sso/internal/config"
+	"sso/internal/logger"
+	"sso/pkg/httpclient"
+)
+
+// voiceChannel calls the phone and reads the code aloud.
+type voiceChannel struct {
+	templates OTPTemplates
+	smsc      *smscProvider
+}
+
+func newVoiceChannel(templates OTPTemplates, smsCfg config.SMSConfig, smscClient *httpclient.Client, logger *logger.Logger) (OTPChannel, error) {
+	smsc, err := newSMSCClient(smsCfg, smscClient, logger)
+	if err != nil {
+		return nil, err
+	}
+	return &voiceChannel{
+		templates: templates,
+		smsc:      smsc,
+	}, nil
+}
+
+func (c *voiceChannel) Name() string {
+	return ChannelVoice
+}
+
+// Send spaces the digits out so that text-to-speech reads them one by one.
+func (c *voiceChannel) Send(phone, code string, msg OTPMessage) error {
+	digits := strings.Join(strings.Split(code, ""), " ")
+	_, err := c.smsc.Call(phone, c.templates.Render(ChannelVoice, digits, msg))
+	return err
+}
+
+// flashCallChannel places a call that is dropped before it is answered; the
+// last digits of the calling number are the code.
+type flashCallChannel struct {
+	smsc *smscProvider
+}
+
+func newFlashCallChannel(smsCfg config.SMSConfig, smscClient *httpclient.Client, logger *logger.Logger) (OTPChannel, error) {
+	smsc, err := newSMSCClient(smsCfg, smscClient, logger)
+	if err != nil {
+		return nil, err
+	}
+	return &flashCallChannel{smsc: smsc}, nil
+}
+
+func (c *flashCallChannel) Name() string {
+	return ChannelFlashCall
+}
+
+func (c *flashCallChannel) Send(phone, code string, msg OTPMessage) error {
+	return fmt.Errorf("flash call codes are chosen by the provider")
+}
+
+// SendChoosingCode asks SMSC for a flash call; with mes=code SMSC picks the
+// calling number and returns its last digits.
+func (c *flashCallChannel) SendChoosingCode(phone string, msg OTPMessage) (string, error) {
+	resp, err := c.smsc.Call(phone, "code")
+	if err != nil {
+		return "", err
+	}
+	if resp.Code == "" {
+		return "", fmt.Errorf("SMSC did not return a flash call code")
+	}
+	return resp.Code, nil
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"sso/internal/config"
+)
+
+// whatsAppChannel sends an authentication template through the WhatsApp
+// Cloud API.
+type whatsAppChannel struct {
+	url      string
+	token    string
+	template string
+	language string
+	client   *http.Client
+}
+
+func newWhatsAppChannel(cfg config.ChannelConfig, timeout time.Duration) (OTPChannel, error) {
+	if cfg.WhatsAppPhoneNumberID == "" || cfg.WhatsAppToken == "" {
+		return nil, fmt.Errorf("WHATSAPP_PHONE_NUMBER_ID and WHATSAPP_TOKEN are required for the whatsapp channel")
+	}
+
+	return &whatsAppChannel{
+		url:      strings.TrimRight(cfg.WhatsAppURL, "/") + "/" + cfg.WhatsAppPhoneNumberID + "/messages",
+		token:    cfg.WhatsAppToken,
+		template: cfg.WhatsAppTemplate,
+		language: cfg.WhatsAppLanguage,
+		client:   &http.Client{Timeout: timeout},
+	}, nil
+}
+
+func (c *whatsAppChannel) Name() string {
+	return ChannelWhatsApp
+}
+
+func (c *whatsAppChannel) Send(phone, code string, msg OTPMessage) error {
+	body := map[string]any{
+		"messaging_product": "whatsapp",
+		"to":                phone,
+		"type":              "template",
+		"template": map[string]any{
+			"name":     c.template,
+			"language": map[string]string{"code": c.language},
+			"components": []map[string]any{
+				{
+					"type":       "body",
+					"parameters": []map[string]string{{"type": "text", "text": code}},
+				},
+			},
+		},
+	}
+
+	return postMessengerJSON(c.client, c.url, c.token, body, nil)
+}
+
+// telegramChannel sends the code through the Telegram Gateway API, which
+// reaches users by phone number and renders the message itself.
+type telegramChannel struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func newTelegramChannel(cfg config.ChannelConfig, timeout time.Duration) (OTPChannel, error) {
+	if cfg.TelegramGatewayToken == "" {
+		return nil, fmt.Errorf("TELEGRAM_GATEWAY_TOKEN is required for the telegram channel")
+	}
+
+	return &telegramChannel{
+		url:    strings.TrimRight(cfg.TelegramGatewayURL, "/") + "/sendVerificationMessage",
+		token:  cfg.TelegramGatewayToken,
+		client: &http.Client{Timeout: timeout},
+	}, nil
+}
+
+func (c *telegramChannel) Name() string {
+	return ChannelTelegram
+}
+
+func (c *telegramChannel) Send(phone, code string, msg OTPMessage) error {
+	body := map[string]string{
+		"phone_number": "+" + phone,
+		"code":         code,
+	}
+
+	var result struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := postMessengerJSON(c.client, c.url, c.token, body, &result); err != nil {
+		return err
+	}
+	if !result.OK {
+		return fmt.Errorf("telegram gateway error: %s", result.Error)
+	}
+	return nil
+}
+
+func postMessengerJSON(client *http.Client, url, token string, body, result any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("request returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	if result != nil {
+		if err := json.Unmarshal(respBody, result); err != nil {
+			return fmt.Errorf("failed to parse response: %w", err)
+		}
+	}
+	return nil
+}
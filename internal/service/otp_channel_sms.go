@@ -0,0 +1,21 @@
+package service
+
+type smsChannel struct {
+	templates  OTPTemplates
+	smsService SMSService
+}
+
+func newSMSChannel(templates OTPTemplates, smsService SMSService) OTPChannel {
+	return &smsChannel{
+		templates:  templates,
+		smsService: smsService,
+	}
+}
+
+func (c *smsChannel) Name() string {
+	return ChannelSMS
+}
+
+func (c *smsChannel) Send(phone, code string, msg OTPMessage) error {
+	return c.smsService.Send(phone, c.templates.Render(ChannelSMS, code, msg))
+}
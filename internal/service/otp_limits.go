@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"net/netip"
+	"time"
+
+	"sso/pkg/apperror"
+)
+
+type sendLimit struct {
+	subject string
+	window  time.Duration
+	max     int
+}
+
+// sendLimits lists the sliding-window caps a code request is counted against.
+// Phone limits are kept per channel; IP and device limits span all channels
+// and keep their original "sms:" subjects.
+func (s *SSOAuthService) sendLimits(channel, phone, ip, deviceUUID string) []sendLimit {
+	limits := []sendLimit{
+		{channel + ":" + phoneSubject(phone), time.Hour, s.OTP.HourlyLimit(channel)},
+		{channel + ":" + phoneSubject(phone), 24 * time.Hour, s.OTP.DailyLimit(channel)},
+	}
+	if ip != "" {
+		limits = append(limits, sendLimit{"sms:" + ipSubject(ipNetwork(ip)), time.Hour, s.OTP.IPHourlyLimit})
+	}
+	if deviceUUID != "" {
+		limits = append(limits, sendLimit{"sms:device:" + deviceUUID, time.Hour, s.OTP.DeviceHourlyLimit})
+	}
+	return limits
+}
+
+// ipNetwork returns the /64 an IPv6 address is in, as a single host is given a
+// whole /64 and could otherwise spread its requests over it. IPv4 addresses
+// are returned as they are.
+func ipNetwork(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil || !addr.Is6() || addr.Is4In6() {
+		return ip
+	}
+	prefix, _ := addr.WithZone("").Prefix(64)
+	return prefix.String()
+}
+
+// reserveSend enforces the resend cooldown and the send quotas and, when the
+// request passes, counts it against them. Concurrent requests may overshoot a
+// quota by a few; the cooldown is what keeps a single phone from flooding.
+func (s *SSOAuthService) reserveSend(ctx context.Context, channel, phone, ip, deviceUUID string) error {
+	limits := s.sendLimits(channel, phone, ip, deviceUUID)
+
+	for _, limit := range limits {
+		if limit.max <= 0 {
+			continue
+		}
+		count, oldest, err := s.CodeCache.CountEvents(ctx, limit.subject, limit.window)
+		if err != nil {
+			return apperror.Internal(err, "error checking send quota")
+		}
+		if count >= int64(limit.max) {
+			s.Logger.WarnContext(ctx, "Verification code quota exceeded",
+				"subject", limit.subject,
+				"window", limit.window,
+				"limit", limit.max)
+			return apperror.ErrSMSQuotaExceeded.WithRetryAfter(time.Until(oldest.Add(limit.window)))
+		}
+	}
+
+	if cooldown := s.OTP.Cooldown(channel); cooldown > 0 {
+		remaining, err := s.CodeCache.StartCooldown(ctx, channel+":"+phoneSubject(phone), cooldown)
+		if err != nil {
+			return apperror.Internal(err, "error checking resend cooldown")
+		}
+		if remaining > 0 {
+			return apperror.ErrResendCooldown.WithRetryAfter(remaining)
+		}
+	}
+
+	recorded := make(map[string]bool, len(limits))
+	for _, limit := range limits {
+		if recorded[limit.subject] {
+			continue
+		}
+		recorded[limit.subject] = true
+		if err := s.CodeCache.RecordEvent(ctx, limit.subject, 24*time.Hour); err != nil {
+			s.Logger.WarnContext(ctx, "Failed to record verification code send", "subject", limit.subject, "error", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"sso/pkg/apperror"
+)
+
+// verificationSession is what /verification leaves in the cache under the
+// opaque id it returns. The code is only kept as a salted hash, and the session
+// remembers the client it was requested from so that /login can tell when the
+// code is entered somewhere else.
+type verificationSession struct {
+	Phone      string `json:"phone"`
+	CodeHash   string `json:"code_hash"`
+	Salt       string `json:"salt"`
+	Channel    string `json:"channel"`
+	Platform   string `json:"platform"`
+	DeviceUUID string `json:"device_uuid"`
+	IP         string `json:"ip"`
+	// TestAccount marks codes of test accounts, which were never sent and
+	// so are left out of the fraud statistics when entered.
+	TestAccount bool `json:"test_account,omitempty"`
+}
+
+func newVerificationSession(phone, code, channel string, req VerificationRequest) (*verificationSession, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	return &verificationSession{
+		Phone:      phone,
+		CodeHash:   hashCode(salt, code),
+		Salt:       hex.EncodeToString(salt),
+		Channel:    channel,
+		Platform:   req.Platform,
+		DeviceUUID: req.DeviceUUID,
+		IP:         req.IP,
+	}, nil
+}
+
+func hashCode(salt []byte, code string) string {
+	mac := hmac.New(sha256.New, salt)
+	mac.Write([]byte(code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// matches reports whether code is the one the session was created for.
+func (v *verificationSession) matches(code string) bool {
+	salt, err := hex.DecodeString(v.Salt)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal([]byte(hashCode(salt, code)), []byte(v.CodeHash))
+}
+
+// saveVerificationSession stores session under a new id and returns the id. Any
+// earlier session for the same phone stops being valid.
+func (s *SSOAuthService) saveVerificationSession(ctx context.Context, session *verificationSession) (string, error) {
+	id, err := generateOpaqueToken()
+	if err != nil {
+		return "", apperror.Internal(err, "error generating verification session")
+	}
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		return "", apperror.Internal(err, "error encoding verification session")
+	}
+
+	if err := s.CodeCache.SaveVerification(ctx, id, session.Phone, data, s.OTP.CodeTTL); err != nil {
+		return "", apperror.Internal(err, "error saving verification session to cache")
+	}
+	return id, nil
+}
+
+// loadVerificationSession returns the session for id, or ErrCodeExpired when
+// there is none.
+func (s *SSOAuthService) loadVerificationSession(ctx context.Context, id string) (*verificationSession, error) {
+	if id == "" {
+		return nil, apperror.ErrInvalidRequest.WithMessage("verification_id is required")
+	}
+
+	data, err := s.CodeCache.GetVerification(ctx, id)
+	if err != nil {
+		return nil, apperror.Internal(err, "error retrieving verification session from cache")
+	}
+	if data == nil {
+		return nil, apperror.ErrCodeExpired
+	}
+
+	var session verificationSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, apperror.Internal(err, "error decoding verification session")
+	}
+	return &session, nil
+}
+
+// checkVerificationClient rejects a code entered from another device than the
+// one that requested it, and invalidates the session so the code cannot be
+// tried again. A changed IP is only rejected with BindIP. The platform is not
+// compared: the login is made on the one stored in the session.
+func (s *SSOAuthService) checkVerificationClient(ctx context.Context, id string, session *verificationSession, deviceUUID, ip string) error {
+	var mismatch string
+	switch {
+	case session.DeviceUUID != deviceUUID:
+		mismatch = "device"
+	case session.IP != ip && s.OTP.BindIP:
+		mismatch = "ip"
+	}
+
+	if mismatch == "" {
+		if session.IP != ip {
+			s.Logger.WarnContext(ctx, "Verification code entered from another IP",
+				"phone", session.Phone,
+				"requested_ip", session.IP,
+				"ip", ip)
+		}
+		return nil
+	}
+
+	s.Logger.WarnContext(ctx, "Verification code entered from another client",
+		"phone", session.Phone,
+		"mismatch", mismatch,
+		"platform", session.Platform,
+		"requested_ip", session.IP,
+		"ip", ip)
+
+	if err := s.CodeCache.DeleteVerification(ctx, id); err != nil {
+		s.Logger.WarnContext(ctx, "Failed to invalidate verification session", "error", err)
+	}
+	return apperror.ErrVerificationMismatch
+}
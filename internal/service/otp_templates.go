@@ -0,0 +1,143 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"sso/internal/config"
+	"sso/internal/logger"
+
+	"golang.org/x/text/language"
+	"gopkg.in/yaml.v3"
+)
+
+// OTPTemplates renders verification messages from the template file and picks
+// the language they are written in.
+type OTPTemplates interface {
+	// Language returns the best supported language for an Accept-Language
+	// header or a bare language code, or "" when none is supported.
+	Language(preferences string) string
+	DefaultLanguage() string
+	Render(channel, code string, msg OTPMessage) string
+}
+
+type otpTemplateFile struct {
+	DefaultLang string        `yaml:"default_lang"`
+	Templates   []otpTemplate `yaml:"templates"`
+}
+
+type otpTemplate struct {
+	Channel  string `yaml:"channel"`
+	Lang     string `yaml:"lang"`
+	Platform string `yaml:"platform"`
+	Brand    string `yaml:"brand"`
+	Text     string `yaml:"text"`
+}
+
+type otpTemplates struct {
+	defaultLang string
+	languages   map[string]bool
+	templates   map[string]string
+	fallbacks   map[string]string
+}
+
+// NewOTPTemplates loads cfg.TemplatesFile. A missing file is not fatal: every
+// message then uses the per-channel fallback template from the environment.
+func NewOTPTemplates(cfg config.ChannelConfig, logger *logger.Logger) (OTPTemplates, error) {
+	t := &otpTemplates{
+		defaultLang: strings.ToLower(cfg.DefaultLang),
+		languages:   map[string]bool{strings.ToLower(cfg.DefaultLang): true},
+		templates:   make(map[string]string),
+		fallbacks: map[string]string{
+			ChannelSMS:   cfg.SMSTemplate,
+			ChannelVoice: cfg.VoiceTemplate,
+		},
+	}
+
+	data, err := os.ReadFile(cfg.TemplatesFile)
+	if os.IsNotExist(err) {
+		logger.Warn("OTP template file not found, using fallback templates", "path", cfg.TemplatesFile)
+		return t, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OTP templates: %w", err)
+	}
+
+	var file otpTemplateFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse OTP templates %s: %w", cfg.TemplatesFile, err)
+	}
+
+	if file.DefaultLang != "" {
+		t.defaultLang = strings.ToLower(file.DefaultLang)
+		t.languages[t.defaultLang] = true
+	}
+	for _, tpl := range file.Templates {
+		if tpl.Channel == "" || tpl.Lang == "" || tpl.Text == "" {
+			return nil, fmt.Errorf("OTP template needs channel, lang and text: %+v", tpl)
+		}
+		lang := strings.ToLower(tpl.Lang)
+		t.languages[lang] = true
+		t.templates[templateKey(tpl.Channel, lang, tpl.Platform, tpl.Brand)] = tpl.Text
+	}
+
+	return t, nil
+}
+
+func templateKey(channel, lang, platform, brand string) string {
+	return strings.ToLower(channel + "|" + lang + "|" + platform + "|" + brand)
+}
+
+func (t *otpTemplates) Language(preferences string) string {
+	tags, _, err := language.ParseAcceptLanguage(preferences)
+	if err != nil {
+		return ""
+	}
+	for _, tag := range tags {
+		base, _ := tag.Base()
+		if t.languages[base.String()] {
+			return base.String()
+		}
+	}
+	return ""
+}
+
+func (t *otpTemplates) DefaultLanguage() string {
+	return t.defaultLang
+}
+
+// Render fills in the template for channel. The SMS Retriever signature only
+// means something to Android apps and is left out for other platforms.
+func (t *otpTemplates) Render(channel, code string, msg OTPMessage) string {
+	signature := ""
+	if msg.Platform == "android" {
+		signature = msg.Signature
+	}
+
+	text := strings.ReplaceAll(t.lookup(channel, msg), "{code}", code)
+	text = strings.ReplaceAll(text, "{signature}", signature)
+	return strings.TrimSpace(text)
+}
+
+// lookup returns the most specific template for msg, trying msg.Lang first
+// and the default language second.
+func (t *otpTemplates) lookup(channel string, msg OTPMessage) string {
+	for _, lang := range []string{msg.Lang, t.defaultLang} {
+		if lang == "" {
+			continue
+		}
+		candidates := []string{
+			templateKey(channel, lang, msg.Platform, msg.Brand),
+			templateKey(channel, lang, msg.Platform, ""),
+			templateKey(channel, lang, "", msg.Brand),
+			templateKey(channel, lang, "", ""),
+		}
+		for _, key := range candidates {
+			if text, ok := t.templates[key]; ok {
+				return text
+			}
+		}
+	}
+	return t.fallbacks[channel]
+}
@@ -4,18 +4,45 @@ import (
 	"context"
 	"fmt"
 	"log"
+	mathrand "math/rand/v2"
+	"strconv"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 )
 
 type CacheService interface {
-	SaveCode(ctx context.Context, phone, code string, ttl time.Duration) error
-	GetCode(ctx context.Context, phone string) (string, error)
-	DeleteCode(ctx context.Context, phone string) error
+	SaveVerification(ctx context.Context, id, phone string, data []byte, ttl time.Duration) error
+	GetVerification(ctx context.Context, id string) ([]byte, error)
+	DeleteVerification(ctx context.Context, id string) error
+	DeletePhoneVerification(ctx context.Context, phone string) error
 
 	AddToBlacklist(ctx context.Context, token string, ttl time.Duration) error
 	IsBlacklisted(ctx context.Context, token string) (bool, error)
+	AddFamilyToBlacklist(ctx context.Context, familyID string, ttl time.Duration) error
+	IsFamilyBlacklisted(ctx context.Context, familyID string) (bool, error)
+
+	SaveAuthRequest(ctx context.Context, id string, data []byte, ttl time.Duration) error
+	GetAuthRequest(ctx context.Context, id string) ([]byte, error)
+	DeleteAuthRequest(ctx context.Context, id string) error
+	SaveAuthCode(ctx context.Context, code string, data []byte, ttl time.Duration) error
+	ConsumeAuthCode(ctx context.Context, code string) ([]byte, error)
+
+	IncrementAttempts(ctx context.Context, subject string, window time.Duration) (int64, error)
+	ResetAttempts(ctx context.Context, subject string) error
+	IncrementLockoutLevel(ctx context.Context, subject string, ttl time.Duration) (int64, error)
+	ResetLockoutLevel(ctx context.Context, subject string) error
+	SetLockout(ctx context.Context, subject string, ttl time.Duration) error
+	GetLockout(ctx context.Context, subject string) (time.Duration, error)
+
+	StartCooldown(ctx context.Context, subject string, ttl time.Duration) (time.Duration, error)
+	CountEvents(ctx context.Context, subject string, window time.Duration) (int64, time.Time, error)
+	RecordEvent(ctx context.Context, subject string, retention time.Duration) error
+
+	SaveFraudRule(ctx context.Context, prefix string, data []byte) error
+	GetFraudRules(ctx context.Context, prefixes []string) (map[string][]byte, error)
+	ListFraudRules(ctx context.Context) (map[string][]byte, error)
+	DeleteFraudRule(ctx context.Context, prefix string) error
 }
 
 type RedisCache struct {
@@ -31,29 +58,63 @@ func NewRedisCache(client *redis.Client) *RedisCache {
 	}
 }
 
-func (r *RedisCache) SaveCode(ctx context.Context, phone, code string, ttl time.Duration) error {
-	err := r.client.Set(ctx, phone, code, ttl).Err()
+// SaveVerification stores a verification session and makes it the phone's
+// current one, dropping the session it replaces.
+func (r *RedisCache) SaveVerification(ctx context.Context, id, phone string, data []byte, ttl time.Duration) error {
+	phoneKey := fmt.Sprintf("otp_phone:%s", phone)
+	previous, err := r.client.Get(ctx, phoneKey).Result()
+	if err != nil && err != redis.Nil {
+		return fmt.Errorf("failed to get verification session for phone %s: %w", phone, err)
+	}
+
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if previous != "" {
+			pipe.Del(ctx, fmt.Sprintf("otp_session:%s", previous))
+		}
+		pipe.Set(ctx, fmt.Sprintf("otp_session:%s", id), data, ttl)
+		pipe.Set(ctx, phoneKey, id, ttl)
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to save code %s to Redis for phone %s: %w", code, phone, err)
+		return fmt.Errorf("failed to save verification session for phone %s: %w", phone, err)
 	}
 	return nil
 }
 
-func (r *RedisCache) GetCode(ctx context.Context, phone string) (string, error) {
-	val, err := r.client.Get(ctx, phone).Result()
+func (r *RedisCache) GetVerification(ctx context.Context, id string) ([]byte, error) {
+	val, err := r.client.Get(ctx, fmt.Sprintf("otp_session:%s", id)).Bytes()
 	if err == redis.Nil {
-		return "", nil
+		return nil, nil
 	}
 	if err != nil {
-		return "", fmt.Errorf("failed to get code for phone %s from Redis: %w", phone, err)
+		return nil, fmt.Errorf("failed to get verification session: %w", err)
 	}
 	return val, nil
 }
 
-func (r *RedisCache) DeleteCode(ctx context.Context, phone string) error {
-	err := r.client.Del(ctx, phone).Err()
+func (r *RedisCache) DeleteVerification(ctx context.Context, id string) error {
+	err := r.client.Del(ctx, fmt.Sprintf("otp_session:%s", id)).Err()
 	if err != nil {
-		return fmt.Errorf("failed to delete code for phone %s from Redis: %w", phone, err)
+		return fmt.Errorf("failed to delete verification session: %w", err)
+	}
+	return nil
+}
+
+// DeletePhoneVerification drops whatever verification session is current for
+// phone.
+func (r *RedisCache) DeletePhoneVerification(ctx context.Context, phone string) error {
+	phoneKey := fmt.Sprintf("otp_phone:%s", phone)
+	id, err := r.client.Get(ctx, phoneKey).Result()
+	if err == redis.Nil {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get verification session for phone %s: %w", phone, err)
+	}
+
+	err = r.client.Del(ctx, fmt.Sprintf("otp_session:%s", id), phoneKey).Err()
+	if err != nil {
+		return fmt.Errorf("failed to delete verification session for phone %s: %w", phone, err)
 	}
 	return nil
 }
@@ -79,4 +140,277 @@ func (r *RedisCache) IsBlacklisted(ctx context.Context, token string) (bool, err
 	return val == "1", nil
 }
 
+// AddFamilyToBlacklist rejects every access token issued to the refresh token
+// family, including ones a refresh has since replaced.
+func (r *RedisCache) AddFamilyToBlacklist(ctx context.Context, familyID string, ttl time.Duration) error {
+	key := fmt.Sprintf("blacklist_family:%s", familyID)
+	err := r.client.Set(ctx, key, "1", ttl).Err()
+	if err != nil {
+		return fmt.Errorf("failed to add token family to blacklist: %w", err)
+	}
+	return nil
+}
+
+func (r *RedisCache) IsFamilyBlacklisted(ctx context.Context, familyID string) (bool, error) {
+	key := fmt.Sprintf("blacklist_family:%s", familyID)
+	val, err := r.client.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check token family in blacklist: %w", err)
+	}
+	return val == "1", nil
+}
+
+func (r *RedisCache) SaveAuthRequest(ctx context.Context, id string, data []byte, ttl time.Duration) error {
+	key := fmt.Sprintf("oidc_auth_request:%s", id)
+	err := r.client.Set(ctx, key, data, ttl).Err()
+	if err != nil {
+		return fmt.Errorf("failed to save authorization request: %w", err)
+	}
+	return nil
+}
+
+func (r *RedisCache) GetAuthRequest(ctx context.Context, id string) ([]byte, error) {
+	key := fmt.Sprintf("oidc_auth_request:%s", id)
+	val, err := r.client.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get authorization request: %w", err)
+	}
+	return val, nil
+}
+
+func (r *RedisCache) DeleteAuthRequest(ctx context.Context, id string) error {
+	key := fmt.Sprintf("oidc_auth_request:%s", id)
+	err := r.client.Del(ctx, key).Err()
+	if err != nil {
+		return fmt.Errorf("failed to delete authorization request: %w", err)
+	}
+	return nil
+}
+
+func (r *RedisCache) SaveAuthCode(ctx context.Context, code string, data []byte, ttl time.Duration) error {
+	key := fmt.Sprintf("oidc_code:%s", code)
+	err := r.client.Set(ctx, key, data, ttl).Err()
+	if err != nil {
+		return fmt.Errorf("failed to save authorization code: %w", err)
+	}
+	return nil
+}
+
+// ConsumeAuthCode reads and deletes the code in one transaction so that an
+// authorization code can be redeemed only once.
+func (r *RedisCache) ConsumeAuthCode(ctx context.Context, code string) ([]byte, error) {
+	key := fmt.Sprintf("oidc_code:%s", code)
+
+	var get *redis.StringCmd
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		get = pipe.Get(ctx, key)
+		pipe.Del(ctx, key)
+		return nil
+	})
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume authorization code: %w", err)
+	}
+	return get.Bytes()
+}
+
+// incrementWithinWindow increments KEYS[1] and, unless it already expires,
+// makes it expire after ARGV[1] milliseconds, in one step so that a counter
+// is never left without a TTL.
+var incrementWithinWindow = redis.NewScript(`
+local count = redis.call("INCR", KEYS[1])
+if redis.call("PTTL", KEYS[1]) < 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return count
+`)
+
+// IncrementAttempts counts a failed attempt for subject. The counter expires
+// window after the first failure, so the window does not slide.
+func (r *RedisCache) IncrementAttempts(ctx context.Context, subject string, window time.Duration) (int64, error) {
+	key := fmt.Sprintf("otp_attempts:%s", subject)
+	count, err := incrementWithinWindow.Run(ctx, r.client, []string{key}, window.Milliseconds()).Int64()
+	if err != nil {
+		return 0, fmt.Errorf("failed to increment attempts: %w", err)
+	}
+	return count, nil
+}
+
+func (r *RedisCache) ResetAttempts(ctx context.Context, subject string) error {
+	err := r.client.Del(ctx, fmt.Sprintf("otp_attempts:%s", subject)).Err()
+	if err != nil {
+		return fmt.Errorf("failed to reset attempts: %w", err)
+	}
+	return nil
+}
+
+// IncrementLockoutLevel counts lockouts of subject; ttl is how long a lockout
+// keeps counting towards the next, longer one.
+func (r *RedisCache) IncrementLockoutLevel(ctx context.Context, subject string, ttl time.Duration) (int64, error) {
+	key := fmt.Sprintf("otp_lockout_level:%s", subject)
+	var incr *redis.IntCmd
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(ctx, key)
+		pipe.Expire(ctx, key, ttl)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to increment lockout level: %w", err)
+	}
+	return incr.Val(), nil
+}
+
+func (r *RedisCache) ResetLockoutLevel(ctx context.Context, subject string) error {
+	err := r.client.Del(ctx, fmt.Sprintf("otp_lockout_level:%s", subject)).Err()
+	if err != nil {
+		return fmt.Errorf("failed to reset lockout level: %w", err)
+	}
+	return nil
+}
+
+func (r *RedisCache) SetLockout(ctx context.Context, subject string, ttl time.Duration) error {
+	err := r.client.Set(ctx, fmt.Sprintf("otp_lockout:%s", subject), "1", ttl).Err()
+	if err != nil {
+		return fmt.Errorf("failed to set lockout: %w", err)
+	}
+	return nil
+}
+
+// GetLockout returns how long subject stays locked out, zero if it is not.
+func (r *RedisCache) GetLockout(ctx context.Context, subject string) (time.Duration, error) {
+	ttl, err := r.client.PTTL(ctx, fmt.Sprintf("otp_lockout:%s", subject)).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get lockout: %w", err)
+	}
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+// StartCooldown starts a cooldown for subject unless one is running. It
+// returns zero when it started one, otherwise the time left on the running one.
+func (r *RedisCache) StartCooldown(ctx context.Context, subject string, ttl time.Duration) (time.Duration, error) {
+	key := fmt.Sprintf("cooldown:%s", subject)
+	started, err := r.client.SetNX(ctx, key, "1", ttl).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to start cooldown: %w", err)
+	}
+	if started {
+		return 0, nil
+	}
+
+	remaining, err := r.client.PTTL(ctx, key).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get cooldown: %w", err)
+	}
+	if remaining <= 0 {
+		return time.Millisecond, nil
+	}
+	return remaining, nil
+}
+
+// CountEvents returns how many events subject recorded within the sliding
+// window and when the oldest of them happened.
+func (r *RedisCache) CountEvents(ctx context.Context, subject string, window time.Duration) (int64, time.Time, error) {
+	key := fmt.Sprintf("events:%s", subject)
+	since := strconv.FormatInt(time.Now().Add(-window).UnixMilli(), 10)
+
+	oldest, err := r.client.ZRangeByScoreWithScores(ctx, key, &redis.ZRangeBy{
+		Min:   since,
+		Max:   "+inf",
+		Count: 1,
+	}).Result()
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("failed to read events: %w", err)
+	}
+	if len(oldest) == 0 {
+		return 0, time.Time{}, nil
+	}
+
+	count, err := r.client.ZCount(ctx, key, since, "+inf").Result()
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("failed to count events: %w", err)
+	}
+	return count, time.UnixMilli(int64(oldest[0].Score)), nil
+}
+
+// RecordEvent adds an event for subject and drops those older than retention,
+// which must cover the longest window the subject is counted over.
+func (r *RedisCache) RecordEvent(ctx context.Context, subject string, retention time.Duration) error {
+	key := fmt.Sprintf("events:%s", subject)
+	now := time.Now()
+
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, key, &redis.Z{
+			Score:  float64(now.UnixMilli()),
+			Member: fmt.Sprintf("%d-%d", now.UnixNano(), mathrand.Int64()),
+		})
+		pipe.ZRemRangeByScore(ctx, key, "-inf", strconv.FormatInt(now.Add(-retention).UnixMilli(), 10))
+		pipe.Expire(ctx, key, retention)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record event: %w", err)
+	}
+	return nil
+}
+
+const fraudRulesKey = "fraud_rules"
+
+func (r *RedisCache) SaveFraudRule(ctx context.Context, prefix string, data []byte) error {
+	if err := r.client.HSet(ctx, fraudRulesKey, prefix, data).Err(); err != nil {
+		return fmt.Errorf("failed to save fraud rule: %w", err)
+	}
+	return nil
+}
+
+// GetFraudRules returns the rules stored for any of prefixes, keyed by prefix.
+func (r *RedisCache) GetFraudRules(ctx context.Context, prefixes []string) (map[string][]byte, error) {
+	if len(prefixes) == 0 {
+		return map[string][]byte{}, nil
+	}
+
+	values, err := r.client.HMGet(ctx, fraudRulesKey, prefixes...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get fraud rules: %w", err)
+	}
+
+	rules := make(map[string][]byte)
+	for i, value := range values {
+		if data, ok := value.(string); ok {
+			rules[prefixes[i]] = []byte(data)
+		}
+	}
+	return rules, nil
+}
+
+func (r *RedisCache) ListFraudRules(ctx context.Context) (map[string][]byte, error) {
+	values, err := r.client.HGetAll(ctx, fraudRulesKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list fraud rules: %w", err)
+	}
+
+	rules := make(map[string][]byte, len(values))
+	for prefix, data := range values {
+		rules[prefix] = []byte(data)
+	}
+	return rules, nil
+}
+
+func (r *RedisCache) DeleteFraudRule(ctx context.Context, prefix string) error {
+	if err := r.client.HDel(ctx, fraudRulesKey, prefix).Err(); err != nil {
+		return fmt.Errorf("failed to delete fraud rule: %w", err)
+	}
+	return nil
+}
+
 var _ CacheService = (*RedisCache)(nil)
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"sso/internal/logger"
+	"sso/internal/models"
+	"sso/internal/repository"
+	"sso/pkg/apperror"
+)
+
+// DeviceInfo describes where a session was started from.
+type DeviceInfo struct {
+	Platform   string
+	DeviceUUID string
+	Agent      string
+	IP         string
+}
+
+// SessionService keeps user_access_tokens in step with refresh token families:
+// a session is started by a login, follows every refresh of its family and
+// ends on logout or eviction.
+type SessionService interface {
+	Start(userID int64, familyID, clientID, accessToken string, device DeviceInfo, expireAt time.Time) error
+	Refresh(familyID, accessToken, agent, ip string, expireAt time.Time) error
+	End(accessToken string) error
+	List(userID int64, currentToken string) ([]Session, error)
+	Revoke(userID, sessionID int64) error
+	RevokeOthers(userID int64, currentToken string) (int, error)
+}
+
+// Session is an active session as shown to its owner.
+type Session struct {
+	ID         int64
+	ClientID   string
+	Platform   string
+	DeviceUUID string
+	Agent      string
+	IP         string
+	LoginAt    time.Time
+	LastSeenAt time.Time
+	Current    bool
+}
+
+type sessionService struct {
+	tokenRepo repository.TokenRepository
+	cache     CacheService
+	accessTTL time.Duration
+	maxActive int
+	logger    *logger.Logger
+}
+
+func NewSessionService(
+	tokenRepo repository.TokenRepository,
+	cache CacheService,
+	accessTTL time.Duration,
+	maxActive int,
+	logger *logger.Logger,
+) SessionService {
+	return &sessionService{
+		tokenRepo: tokenRepo,
+		cache:     cache,
+		accessTTL: accessTTL,
+		maxActive: maxActive,
+		logger:    logger,
+	}
+}
+
+// Start records a new session and evicts the user's oldest sessions when the
+// limit is exceeded.
+func (s *sessionService) Start(userID int64, familyID, clientID, accessToken string, device DeviceInfo, expireAt time.Time) error {
+	err := s.tokenRepo.Create(userID, accessToken, familyID, clientID, device.Platform, device.DeviceUUID, device.Agent, device.IP, expireAt)
+	if err != nil {
+		return err
+	}
+
+	if s.maxActive <= 0 {
+		return nil
+	}
+
+	sessions, err := s.tokenRepo.FindActiveByUser(userID)
+	if err != nil {
+		s.logger.Warn("Failed to load sessions for eviction", "user_id", userID, "error", err)
+		return nil
+	}
+
+	excess := len(sessions) - s.maxActive
+	for i := 0; i < len(sessions) && excess > 0; i++ {
+		if sessions[i].FamilyID.String == familyID {
+			continue
+		}
+		s.logger.Info("Evicting oldest session",
+			"user_id", userID,
+			"session_id", sessions[i].ID,
+			"max_active", s.maxActive)
+		if err := s.revoke(&sessions[i]); err != nil {
+			s.logger.Warn("Failed to evict session", "session_id", sessions[i].ID, "error", err)
+		}
+		excess--
+	}
+
+	return nil
+}
+
+func (s *sessionService) Refresh(familyID, accessToken, agent, ip string, expireAt time.Time) error {
+	return s.tokenRepo.UpdateSessionToken(familyID, accessToken, agent, ip, expireAt)
+}
+
+// End closes the session accessToken belongs to. Tokens issued before sessions
+// were recorded have no row and only need the blacklist.
+func (s *sessionService) End(accessToken string) error {
+	session, err := s.tokenRepo.FindAnyByToken(accessToken)
+	if err != nil {
+		return err
+	}
+	if session == nil {
+		return nil
+	}
+	return s.revoke(session)
+}
+
+// List returns the user's active sessions, marking the one currentToken
+// belongs to.
+func (s *sessionService) List(userID int64, currentToken string) ([]Session, error) {
+	rows, err := s.tokenRepo.FindActiveByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]Session, 0, len(rows))
+	for _, row := range rows {
+		loginAt := row.LoginAt.Time
+		if !row.LoginAt.Valid {
+			loginAt = row.CreatedAt.Time
+		}
+		lastSeenAt := row.LastSeenAt.Time
+		if !row.LastSeenAt.Valid {
+			lastSeenAt = loginAt
+		}
+		sessions = append(sessions, Session{
+			ID:         row.ID,
+			ClientID:   row.ClientID.String,
+			Platform:   row.Platform.String,
+			DeviceUUID: row.DeviceUUID.String,
+			Agent:      row.Agent.String,
+			IP:         row.IP.String,
+			LoginAt:    loginAt,
+			LastSeenAt: lastSeenAt,
+			Current:    row.Token.Valid && row.Token.String == currentToken,
+		})
+	}
+	return sessions, nil
+}
+
+// Revoke ends one of the user's own sessions.
+func (s *sessionService) Revoke(userID, sessionID int64) error {
+	rows, err := s.tokenRepo.FindActiveByUser(userID)
+	if err != nil {
+		return err
+	}
+
+	for i := range rows {
+		if rows[i].ID == sessionID {
+			return s.revoke(&rows[i])
+		}
+	}
+	return apperror.ErrSessionNotFound
+}
+
+// RevokeOthers ends every session of the user except the one currentToken
+// belongs to and returns how many were ended.
+func (s *sessionService) RevokeOthers(userID int64, currentToken string) (int, error) {
+	rows, err := s.tokenRepo.FindActiveByUser(userID)
+	if err != nil {
+		return 0, err
+	}
+
+	revoked := 0
+	for i := range rows {
+		if rows[i].Token.Valid && rows[i].Token.String == currentToken {
+			continue
+		}
+		if err := s.revoke(&rows[i]); err != nil {
+			return revoked, err
+		}
+		revoked++
+	}
+	return revoked, nil
+}
+
+// revoke blacklists the session's access tokens, deactivates the session and
+// revokes its refresh token family so it cannot be refreshed. The family is
+// blacklisted as well as the current token, as tokens issued before the last
+// refresh are still unexpired.
+func (s *sessionService) revoke(session *models.UserAccessToken) error {
+	if session.Token.Valid {
+		if err := s.cache.AddToBlacklist(context.Background(), session.Token.String, s.accessTTL); err != nil {
+			return fmt.Errorf("failed to add token to blacklist: %w", err)
+		}
+	}
+
+	if !session.FamilyID.Valid || session.FamilyID.String == "" {
+		return s.tokenRepo.Deactivate(session.Token.String)
+	}
+
+	if err := s.cache.AddFamilyToBlacklist(context.Background(), session.FamilyID.String, s.accessTTL); err != nil {
+		return fmt.Errorf("failed to add token family to blacklist: %w", err)
+	}
+	if err := s.tokenRepo.DeactivateFamily(session.FamilyID.String); err != nil {
+		return err
+	}
+	return s.tokenRepo.RevokeRefreshFamily(session.FamilyID.String)
+}
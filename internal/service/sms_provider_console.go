@@ -0,0 +1,51 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"sso/internal/config"
+	"sso/internal/logger"
+)
+
+// consoleSMSProvider never sends anything. It logs each message, or appends it
+// to a file, so that codes can be read during local development.
+type consoleSMSProvider struct {
+	file   string
+	mu     sync.Mutex
+	logger *logger.Logger
+}
+
+func newConsoleSMSProvider(cfg config.SMSConfig, logger *logger.Logger) SMSProvider {
+	return &consoleSMSProvider{
+		file:   cfg.ConsoleFile,
+		logger: logger,
+	}
+}
+
+func (p *consoleSMSProvider) Name() string {
+	return "console"
+}
+
+func (p *consoleSMSProvider) Send(phone, text string) (*SMSReceipt, error) {
+	if p.file == "" {
+		p.logger.Info("SMS (console provider)", "phone", phone, "text", text)
+		return &SMSReceipt{Parts: 1}, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	f, err := os.OpenFile(p.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SMS file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := fmt.Fprintf(f, "%s\t%s\t%q\n", time.Now().Format(time.RFC3339), phone, text); err != nil {
+		return nil, fmt.Errorf("failed to write SMS file: %w", err)
+	}
+	return &SMSReceipt{Parts: 1}, nil
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"sso/internal/config"
+	"sso/internal/logger"
+)
+
+// httpSMSProvider talks to any gateway that takes the phone and the text as
+// two named parameters and answers with a 2xx status on success. Delivery
+// status is not tracked.
+type httpSMSProvider struct {
+	url        string
+	method     string
+	format     string
+	phoneParam string
+	textParam  string
+	headers    map[string]string
+	client     *http.Client
+	logger     *logger.Logger
+}
+
+func newHTTPSMSProvider(cfg config.SMSConfig, logger *logger.Logger) (SMSProvider, error) {
+	if cfg.HTTPURL == "" {
+		return nil, fmt.Errorf("SMS_HTTP_URL is required for the http provider")
+	}
+
+	format := strings.ToLower(cfg.HTTPFormat)
+	if format != "json" && format != "form" {
+		return nil, fmt.Errorf("SMS_HTTP_FORMAT must be json or form, got %q", cfg.HTTPFormat)
+	}
+
+	return &httpSMSProvider{
+		url:        cfg.HTTPURL,
+		method:     strings.ToUpper(cfg.HTTPMethod),
+		format:     format,
+		phoneParam: cfg.HTTPPhoneParam,
+		textParam:  cfg.HTTPTextParam,
+		headers:    cfg.HTTPHeaders,
+		client:     &http.Client{Timeout: cfg.Timeout},
+		logger:     logger,
+	}, nil
+}
+
+func (p *httpSMSProvider) Name() string {
+	return "http"
+}
+
+func (p *httpSMSProvider) Send(phone, text string) (*SMSReceipt, error) {
+	req, err := p.newRequest(phone, text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build SMS request: %w", err)
+	}
+	for key, value := range p.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send SMS request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("SMS gateway returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return &SMSReceipt{}, nil
+}
+
+// newRequest puts the parameters in the query for GET and in the body
+// otherwise.
+func (p *httpSMSProvider) newRequest(phone, text string) (*http.Request, error) {
+	if p.method == http.MethodGet {
+		params := url.Values{}
+		params.Set(p.phoneParam, phone)
+		params.Set(p.textParam, text)
+
+		separator := "?"
+		if strings.Contains(p.url, "?") {
+			separator = "&"
+		}
+		return http.NewRequest(http.MethodGet, p.url+separator+params.Encode(), nil)
+	}
+
+	if p.format == "form" {
+		params := url.Values{}
+		params.Set(p.phoneParam, phone)
+		params.Set(p.textParam, text)
+
+		req, err := http.NewRequest(p.method, p.url, strings.NewReader(params.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req, nil
+	}
+
+	body, err := json.Marshal(map[string]string{
+		p.phoneParam: phone,
+		p.textParam:  text,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(p.method, p.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
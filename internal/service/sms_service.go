@@ -1,78 +1,249 @@
 package service
 
 import (
-	"crypto/tls"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
-	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"sso/internal/config"
 	"sso/internal/logger"
 	"sso/internal/models"
+	"sso/internal/repository"
+	"sso/pkg/apperror"
+	"sso/pkg/httpclient"
 )
 
-type SMSCService interface {
-	SendVerificationCode(phone, code, signature, platform string) error
+type SMSService interface {
+	Send(phone, text string) error
+	CheckDeliveries() error
+	History(phone string, limit int) ([]models.SMSMessage, error)
 }
 
-type smscService struct {
-	login    string
-	password string
-	apiURL   string
-	logger   *logger.Logger
+// SMSProvider delivers a text to a phone given in international format
+// without the leading "+".
+type SMSProvider interface {
+	Name() string
+	Send(phone, text string) (*SMSReceipt, error)
 }
 
-func NewSMSCService(login, password string, logger *logger.Logger) SMSCService {
-	return &smscService{
-		login:    login,
-		password: password,
-		apiURL:   "https://smsc.kz/sys/send.php",
-		logger:   logger,
-	}
+// SMSReceipt is what a provider reports about an accepted message. Fields the
+// provider does not report are left empty.
+type SMSReceipt struct {
+	MessageID string
+	Cost      string
+	Parts     int
+}
+
+// SMSStatusChecker is implemented by providers that can report whether a
+// message they accepted was delivered.
+type SMSStatusChecker interface {
+	Status(phone, messageID string) (*SMSDeliveryStatus, error)
+}
+
+type SMSDeliveryStatus struct {
+	Status         string
+	ProviderStatus string
+	Error          string
+	DeliveredAt    *time.Time
 }
 
-func (s *smscService) SendVerificationCode(phone, code, signature, platform string) error {
-	messageText := fmt.Sprintf("%s код доступа для авторизации", code)
+// smsService routes each message to the providers configured for the phone's
+// country prefix and fails over to the next one when a provider errors or
+// times out.
+type smsService struct {
+	providers map[string]SMSProvider
+	defaults  []string
+	routes    map[string][]string
+	prefixes  []string
+	messages  repository.SMSMessageRepository
+	cfg       config.SMSConfig
+	logger    *logger.Logger
+}
 
-	if platform == "android" && signature != "" {
-		messageText = fmt.Sprintf("%s\n%s", messageText, signature)
+func NewSMSService(cfg config.SMSConfig, smscClient *httpclient.Client, messages repository.SMSMessageRepository, logger *logger.Logger) (SMSService, error) {
+	s := &smsService{
+		providers: make(map[string]SMSProvider),
+		defaults:  cleanProviderNames(cfg.Providers),
+		routes:    make(map[string][]string, len(cfg.Routes)),
+		messages:  messages,
+		cfg:       cfg,
+		logger:    logger,
+	}
+	if len(s.defaults) == 0 {
+		return nil, fmt.Errorf("SMS_PROVIDERS must name at least one provider")
 	}
 
-	params := url.Values{}
-	params.Set("login", s.login)
-	params.Set("psw", s.password)
-	params.Set("phones", phone)
-	params.Set("mes", messageText)
-	params.Set("fmt", "3")
+	for prefix, chain := range cfg.Routes {
+		prefix = strings.TrimPrefix(strings.TrimSpace(prefix), "+")
+		names := cleanProviderNames(strings.Split(chain, "|"))
+		if prefix == "" || len(names) == 0 {
+			return nil, fmt.Errorf("invalid SMS route %q: %q", prefix, chain)
+		}
+		s.routes[prefix] = names
+		s.prefixes = append(s.prefixes, prefix)
+	}
+	// Longest prefix first so that the most specific route matches.
+	sort.Slice(s.prefixes, func(i, j int) bool {
+		return len(s.prefixes[i]) > len(s.prefixes[j])
+	})
 
-	requestURL := fmt.Sprintf("%s?%s", s.apiURL, params.Encode())
+	chains := append([][]string{s.defaults}, mapValues(s.routes)...)
+	for _, chain := range chains {
+		for _, name := range chain {
+			if _, ok := s.providers[name]; ok {
+				continue
+			}
+			provider, err := newSMSProvider(name, cfg, smscClient, logger)
+			if err != nil {
+				return nil, err
+			}
+			s.providers[name] = provider
+		}
+	}
 
-	client := &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		},
+	return s, nil
+}
+
+func newSMSProvider(name string, cfg config.SMSConfig, smscClient *httpclient.Client, logger *logger.Logger) (SMSProvider, error) {
+	switch name {
+	case "smsc":
+		return newSMSCProvider(cfg, smscClient, logger)
+	case "http":
+		return newHTTPSMSProvider(cfg, logger)
+	case "console":
+		return newConsoleSMSProvider(cfg, logger), nil
 	}
+	return nil, fmt.Errorf("unknown SMS provider %q", name)
+}
 
-	resp, err := client.Get(requestURL)
-	if err != nil {
-		s.logger.Error("Failed to send HTTP request", "error", err)
-		return fmt.Errorf("failed to send SMS request: %w", err)
+func cleanProviderNames(names []string) []string {
+	var result []string
+	for _, name := range names {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			result = append(result, name)
+		}
 	}
-	defer resp.Body.Close()
+	return result
+}
 
-	body, err := io.ReadAll(resp.Body)
+func mapValues(m map[string][]string) [][]string {
+	values := make([][]string, 0, len(m))
+	for _, v := range m {
+		values = append(values, v)
+	}
+	return values
+}
+
+// route returns the provider chain for phone.
+func (s *smsService) route(phone string) []string {
+	for _, prefix := range s.prefixes {
+		if strings.HasPrefix(phone, prefix) {
+			return s.routes[prefix]
+		}
+	}
+	return s.defaults
+}
+
+// Send delivers text and records the message in sms_messages. Failing to
+// record it does not stop the message from being sent.
+func (s *smsService) Send(phone, text string) error {
+	messageID, err := s.messages.Create(phone)
 	if err != nil {
-		return fmt.Errorf("failed to read response body: %w", err)
+		s.logger.Warn("Failed to record SMS message", "phone", phone, "error", err)
 	}
 
-	var smscResp models.SMSCResponse
-	if err := json.Unmarshal(body, &smscResp); err != nil {
-		return fmt.Errorf("failed to parse response: %w", err)
+	var errs []error
+	var provider string
+	for _, name := range s.route(phone) {
+		provider = name
+		receipt, err := s.providers[name].Send(phone, text)
+		if err == nil {
+			if len(errs) > 0 {
+				s.logger.Info("SMS delivered after failover", "provider", name, "phone", phone)
+			}
+			if messageID != 0 {
+				err := s.messages.MarkSent(messageID, name, receipt.MessageID, receipt.Cost, receipt.Parts)
+				if err != nil {
+					s.logger.Warn("Failed to record sent SMS", "id", messageID, "error", err)
+				}
+			}
+			return nil
+		}
+		s.logger.Warn("SMS provider failed", "provider", name, "phone", phone, "error", err)
+		errs = append(errs, fmt.Errorf("%s: %w", name, err))
 	}
 
-	if smscResp.Status != 0 {
-		return fmt.Errorf("SMS service error: %s", smscResp.Error)
+	err = fmt.Errorf("all SMS providers failed: %w", errors.Join(errs...))
+	if messageID != 0 {
+		if markErr := s.messages.MarkFailed(messageID, provider, err.Error()); markErr != nil {
+			s.logger.Warn("Failed to record failed SMS", "id", messageID, "error", markErr)
+		}
+	}
+	return err
+}
+
+// CheckDeliveries asks providers that report delivery about messages they
+// accepted within the status window and stores the answers.
+func (s *smsService) CheckDeliveries() error {
+	checkers := make(map[string]SMSStatusChecker)
+	var names []string
+	for name, provider := range s.providers {
+		if checker, ok := provider.(SMSStatusChecker); ok {
+			checkers[name] = checker
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	messages, err := s.messages.FindUndelivered(names, time.Now().Add(-s.cfg.StatusWindow), s.cfg.StatusBatch)
+	if err != nil {
+		return err
 	}
 
+	for _, message := range messages {
+		if message.ProviderMessageID == "" {
+			continue
+		}
+
+		status, err := checkers[message.Provider].Status(message.Phone, message.ProviderMessageID)
+		if err != nil {
+			s.logger.Warn("Failed to check SMS status",
+				"id", message.ID,
+				"provider", message.Provider,
+				"error", err)
+			continue
+		}
+
+		err = s.messages.UpdateStatus(message.ID, status.Status, status.ProviderStatus, status.Error, status.DeliveredAt)
+		if err != nil {
+			return err
+		}
+		if status.Status != models.SMSStatusSent {
+			s.logger.Info("SMS delivery status updated",
+				"id", message.ID,
+				"phone", message.Phone,
+				"status", status.Status,
+				"provider_status", status.ProviderStatus)
+		}
+	}
 	return nil
 }
+
+// History returns the most recent messages sent to phone, newest first.
+func (s *smsService) History(phone string, limit int) ([]models.SMSMessage, error) {
+	phone = regexp.MustCompile(`[^\d]`).ReplaceAllString(phone, "")
+	if phone == "" {
+		return nil, apperror.ErrInvalidPhone
+	}
+
+	messages, err := s.messages.FindByPhone(phone, limit)
+	if err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
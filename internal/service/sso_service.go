@@ -2,19 +2,37 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
-	"math/rand/v2"
-	"regexp"
+	mathrand "math/rand/v2"
+	"strings"
 	"time"
 
+	"sso/internal/config"
 	"sso/internal/logger"
+	"sso/internal/models"
 	"sso/internal/repository"
+	"sso/pkg/apperror"
+	"sso/pkg/phone"
 )
 
 type SSOService interface {
-	Verification(phone, signature, platform string) error
-	Login(phone, code, platform, deviceUUID, agent, ip, websiteID string) (string, error)
-	Logout(token string) error
+	Verification(ctx context.Context, req VerificationRequest) (*VerificationResult, error)
+	Authenticate(ctx context.Context, verificationID, phone, code, deviceUUID, agent, ip, websiteID string, consent Consent) (*models.User, string, error)
+	Login(ctx context.Context, verificationID, phone, code, deviceUUID, agent, ip, websiteID string, consent Consent) (*TokenPair, error)
+	IssueTokens(user *models.User, clientID, scope string, device DeviceInfo) (*TokenPair, error)
+	Refresh(ctx context.Context, refreshToken, clientID, agent, ip string) (*TokenPair, error)
+	Logout(ctx context.Context, token string) error
+}
+
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int64
+	Scope        string
 }
 
 type SSOAuthService struct {
@@ -23,182 +41,444 @@ type SSOAuthService struct {
 	TokenRepo       repository.TokenRepository
 	CodeCache       CacheService
 	JWTService      JWTService
-	SMSService      SMSCService
-	MindboxService  AuthMindboxService
+	Channels        OTPChannelRegistry
+	Templates       OTPTemplates
+	Phones          *phone.Parser
+	MindboxOutbox   MindboxOutboxService
+	Consent         ConsentService
+	Sessions        SessionService
+	Fraud           FraudService
+	RefreshTTL      time.Duration
+	OTP             config.OTPConfig
 	Logger          *logger.Logger
 }
 
-func validatePhone(phone string) (string, error) {
-	phone = regexp.MustCompile(`[^\d]`).ReplaceAllString(phone, "")
-	if len(phone) != 11 {
-		return phone, fmt.Errorf("invalid phone number: phone number must be 11 digits")
-	}
-	if phone[0] != '7' && phone[0] != '8' {
-		return phone, fmt.Errorf("invalid phone number: phone number must start with 7 or 8")
-	}
-	if phone[0] == '8' {
-		phone = "7" + phone[1:]
+// validatePhone normalizes phone to E.164.
+func (s *SSOAuthService) validatePhone(input string) (string, error) {
+	number, err := s.Phones.Parse(input)
+	if err != nil {
+		return "", apperror.ErrInvalidPhone.WithMessage(err.Error()).Wrap(err)
 	}
-	return phone, nil
+	return number.E164(), nil
 }
 
 func generateCode() string {
-	return fmt.Sprintf("%04d", rand.IntN(9000)+1000)
+	return fmt.Sprintf("%04d", mathrand.IntN(9000)+1000)
+}
+
+// VerificationRequest asks for a code to be sent to Phone over Channel. An
+// empty Channel selects the default one. AcceptLanguage and Brand select the
+// message template. CaptchaToken is only needed once the fraud checks ask for
+// a challenge.
+type VerificationRequest struct {
+	Phone          string
+	Channel        string
+	Signature      string
+	Platform       string
+	Brand          string
+	AcceptLanguage string
+	DeviceUUID     string
+	Agent          string
+	IP             string
+	CaptchaToken   string
 }
 
-func (s *SSOAuthService) Verification(phone, signature, platform string) error {
-	normalizedPhone, err := validatePhone(phone)
+// VerificationResult tells the client which verification session to log in
+// with, the channel the code went out on and how long it must wait before it
+// may request another code over that channel.
+type VerificationResult struct {
+	ID          string
+	Channel     string
+	ResendAfter time.Duration
+}
+
+// Verification sends a new code and opens a verification session for it.
+func (s *SSOAuthService) Verification(ctx context.Context, req VerificationRequest) (*VerificationResult, error) {
+	normalizedPhone, err := s.validatePhone(req.Phone)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	// A new code would not help while the phone is locked out.
+	if err := s.checkLockout(ctx, normalizedPhone, ""); err != nil {
+		return nil, err
+	}
+
+	channel, err := s.Channels.Get(req.Channel)
+	if err != nil {
+		return nil, err
 	}
 
 	testAccount, err := s.TestAccountRepo.FindByPhone(normalizedPhone)
 	if err != nil {
-		return fmt.Errorf("error checking test account: %w", err)
+		return nil, apperror.Internal(err, "error checking test account")
 	}
 
 	var code string
 	if testAccount != nil {
 		if !testAccount.Code.Valid {
-			return fmt.Errorf("test code is not set for test account")
+			return nil, apperror.ErrInternal.Wrap(fmt.Errorf("test code is not set for test account"))
 		}
 		code = testAccount.Code.String
-		s.Logger.Info("Test verification code generated", "phone", normalizedPhone, "code", code)
+		s.Logger.InfoContext(ctx, "Test verification code issued", "phone", normalizedPhone)
 	} else {
-		code = generateCode()
-
-		go func() {
-			err := s.SMSService.SendVerificationCode(normalizedPhone, code, signature, platform)
-			if err != nil {
-				s.Logger.Error("Async SMS sending failed",
-					"phone", normalizedPhone,
-					"code", code,
-					"error", err)
-			} else {
-				s.Logger.Info("Async SMS sent successfully",
-					"phone", normalizedPhone,
-					"code", code)
-			}
-		}()
+		// Test accounts send nothing, so only real sends are scored and count
+		// against quotas.
+		if err := s.Fraud.Assess(ctx, normalizedPhone, req.IP, req.Agent, req.CaptchaToken); err != nil {
+			return nil, err
+		}
+		if err := s.reserveSend(ctx, channel.Name(), normalizedPhone, req.IP, req.DeviceUUID); err != nil {
+			return nil, err
+		}
 
-		s.Logger.Info("Verification code generated and sent", "phone", normalizedPhone, "code", code)
+		code, err = s.sendCode(ctx, channel, normalizedPhone, OTPMessage{
+			Lang:      s.messageLanguage(ctx, req.AcceptLanguage, normalizedPhone),
+			Platform:  req.Platform,
+			Brand:     req.Brand,
+			Signature: req.Signature,
+		}, func() {
+			s.Fraud.RecordSend(context.WithoutCancel(ctx), normalizedPhone, req.IP, req.Agent)
+		})
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	ttl := 5 * time.Minute
-	err = s.CodeCache.SaveCode(context.Background(), normalizedPhone, code, ttl)
+	session, err := newVerificationSession(normalizedPhone, code, channel.Name(), req)
 	if err != nil {
-		return fmt.Errorf("error saving verification code to cache: %w", err)
+		return nil, apperror.Internal(err, "error hashing verification code")
+	}
+	session.TestAccount = testAccount != nil
+	id, err := s.saveVerificationSession(ctx, session)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	return &VerificationResult{
+		ID:          id,
+		Channel:     channel.Name(),
+		ResendAfter: s.OTP.Cooldown(channel.Name()),
+	}, nil
 }
 
-func (s *SSOAuthService) Login(phone, code, platform, deviceUUID, agent, ip, websiteID string) (string, error) {
-	normalizedPhone, err := validatePhone(phone)
+// messageLanguage prefers the Accept-Language header, then the language stored
+// for an existing user, then the templates' default.
+func (s *SSOAuthService) messageLanguage(ctx context.Context, acceptLanguage, phone string) string {
+	if lang := s.Templates.Language(acceptLanguage); lang != "" {
+		return lang
+	}
+
+	user, err := s.UserRepo.FindByPhone(phone)
 	if err != nil {
+		s.Logger.WarnContext(ctx, "Failed to look up user language", "phone", phone, "error", err)
+	} else if user != nil {
+		if lang := s.Templates.Language(user.Lang); lang != "" {
+			return lang
+		}
+	}
+
+	return s.Templates.DefaultLanguage()
+}
+
+// sendCode delivers a new code over channel. Channels that pick the code
+// themselves are waited for; the others send in the background. Channels take
+// the phone without the leading "+". accepted is called once the provider has
+// taken the code, which for background sends is after sendCode returns.
+func (s *SSOAuthService) sendCode(ctx context.Context, channel OTPChannel, e164 string, msg OTPMessage, accepted func()) (string, error) {
+	phone := strings.TrimPrefix(e164, "+")
+
+	if chooser, ok := channel.(codeChoosingChannel); ok {
+		code, err := chooser.SendChoosingCode(phone, msg)
+		if err != nil {
+			s.Logger.ErrorContext(ctx, "Verification code delivery failed",
+				"phone", phone,
+				"channel", channel.Name(),
+				"error", err)
+			return "", apperror.ErrDeliveryFailed.Wrap(err)
+		}
+		s.Logger.InfoContext(ctx, "Verification code sent", "phone", phone, "channel", channel.Name())
+		accepted()
+		return code, nil
+	}
+
+	code := generateCode()
+
+	go func() {
+		err := channel.Send(phone, code, msg)
+		if err != nil {
+			s.Logger.ErrorContext(ctx, "Async verification code delivery failed",
+				"phone", phone,
+				"channel", channel.Name(),
+				"error", err)
+		} else {
+			s.Logger.InfoContext(ctx, "Async verification code delivered",
+				"phone", phone,
+				"channel", channel.Name())
+			accepted()
+		}
+	}()
+
+	s.Logger.InfoContext(ctx, "Verification code generated and sent", "phone", phone, "channel", channel.Name())
+	return code, nil
+}
+
+// generateOpaqueToken returns a random URL-safe token with 256 bits of entropy.
+func generateOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
 		return "", err
 	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
 
-	storedCode, err := s.CodeCache.GetCode(context.Background(), normalizedPhone)
+// hashToken is used to look refresh tokens up without storing them in plain text.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func generateFamilyID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (s *SSOAuthService) Login(ctx context.Context, verificationID, phone, code, deviceUUID, agent, ip, websiteID string, consent Consent) (*TokenPair, error) {
+	user, platform, err := s.Authenticate(ctx, verificationID, phone, code, deviceUUID, agent, ip, websiteID, consent)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.IssueTokens(user, "", "", DeviceInfo{
+		Platform:   platform,
+		DeviceUUID: deviceUUID,
+		Agent:      agent,
+		IP:         ip,
+	})
+}
+
+// Authenticate checks the code of verification session verificationID and
+// returns the matching user, registering a new one on first login, and the
+// platform the code was requested from, which the login is then made on. The
+// code must be entered from the device that requested it; phone, when given,
+// must be the one the code was sent to. consent is what the user stated on the
+// login form; it is stored before Mindbox is told about the login. It is the
+// user-authentication step for both /login and the OpenID Connect
+// authorization endpoint.
+func (s *SSOAuthService) Authenticate(ctx context.Context, verificationID, phone, code, deviceUUID, agent, ip, websiteID string, consent Consent) (*models.User, string, error) {
+	session, err := s.loadVerificationSession(ctx, verificationID)
 	if err != nil {
-		return "", fmt.Errorf("error retrieving verification code from cache: %w", err)
+		return nil, "", err
 	}
-	if storedCode == "" {
-		return "", fmt.Errorf("verification code expired or not found")
+	normalizedPhone := session.Phone
+	platform := session.Platform
+
+	if phone != "" {
+		requested, err := s.validatePhone(phone)
+		if err != nil {
+			return nil, "", err
+		}
+		if requested != normalizedPhone {
+			return nil, "", apperror.ErrInvalidRequest.WithMessage("verification_id was issued for another phone")
+		}
+	}
+
+	if err := s.checkLockout(ctx, normalizedPhone, ip); err != nil {
+		return nil, "", err
 	}
-	if storedCode != code {
-		return "", fmt.Errorf("invalid verification code")
+	if err := s.checkVerificationClient(ctx, verificationID, session, deviceUUID, ip); err != nil {
+		return nil, "", err
 	}
 
-	err = s.CodeCache.DeleteCode(context.Background(), normalizedPhone)
+	if !session.matches(code) {
+		return nil, "", s.registerFailedAttempt(ctx, normalizedPhone, ip)
+	}
+
+	err = s.CodeCache.DeleteVerification(ctx, verificationID)
 	if err != nil {
-		s.Logger.Warn("Failed to delete verification code", "error", err)
+		s.Logger.WarnContext(ctx, "Failed to delete verification session", "error", err)
 	}
+	s.clearFailedAttempts(ctx, normalizedPhone)
+	if !session.TestAccount {
+		s.Fraud.RecordLogin(ctx, normalizedPhone, ip, agent)
+	}
+	s.Logger.InfoContext(ctx, "Verification code accepted", "phone", normalizedPhone, "channel", session.Channel)
 
 	user, err := s.UserRepo.FindByPhone(normalizedPhone)
 	if err != nil {
-		return "", fmt.Errorf("error finding user in repository: %w", err)
+		return nil, "", apperror.Internal(err, "error finding user in repository")
+	}
+
+	event := MindboxEvent{
+		Platform:   platform,
+		WebsiteID:  websiteID,
+		DeviceUUID: deviceUUID,
+		Agent:      agent,
+	}
+
+	origin := ConsentOrigin{
+		Source:     models.ConsentSourceLogin,
+		Platform:   platform,
+		DeviceUUID: deviceUUID,
+		Agent:      agent,
+		IP:         ip,
 	}
 
 	if user == nil {
-		user, err = s.UserRepo.Create(normalizedPhone)
+		payload, err := event.Encode()
 		if err != nil {
-			return "", fmt.Errorf("error creating user in repository: %w", err)
+			return nil, "", apperror.Internal(err, "error encoding mindbox event")
 		}
-		mindboxWebsiteID := websiteID
-		if mindboxWebsiteID == "" {
-			mindboxWebsiteID = fmt.Sprintf("%d", user.ID)
+		origin.Source = models.ConsentSourceRegister
+		user, err = s.UserRepo.Create(normalizedPhone, consentChanges(nil, consent, origin), payload)
+		if err != nil {
+			return nil, "", apperror.Internal(err, "error creating user in repository")
 		}
+		return user, platform, nil
+	}
 
-		go func() {
-			err := s.MindboxService.RegisterUser(
-				user,
-				platform,
-				mindboxWebsiteID,
-				deviceUUID,
-				agent,
-			)
-			if err != nil {
-				s.Logger.Error("Async Mindbox registration failed",
-					"user_id", user.ID,
-					"phone", user.Phone.String,
-					"error", err)
-			} else {
-				s.Logger.Info("Async Mindbox registration successful",
-					"user_id", user.ID,
-					"phone", user.Phone.String)
-			}
-		}()
-	} else {
-		mindboxWebsiteID := websiteID
-		if mindboxWebsiteID == "" {
-			mindboxWebsiteID = fmt.Sprintf("%d", user.ID)
-		}
+	if _, err := s.Consent.Record(user.ID, consent, origin); err != nil {
+		return nil, "", err
+	}
+	if err := s.MindboxOutbox.EnqueueLogin(user.ID, event); err != nil {
+		// Mindbox is only told about the login; it must not fail because of it.
+		s.Logger.ErrorContext(ctx, "Failed to queue Mindbox login", "user_id", user.ID, "error", err)
+	}
+
+	return user, platform, nil
+}
 
-		go func() {
-			err := s.MindboxService.LoginUser(
-				user,
-				platform,
-				mindboxWebsiteID,
-				"",
-				deviceUUID,
-				agent,
-			)
-			if err != nil {
-				s.Logger.Error("Async Mindbox login failed",
-					"user_id", user.ID,
-					"phone", user.Phone.String,
-					"error", err)
-			} else {
-				s.Logger.Info("Async Mindbox login successful",
-					"user_id", user.ID,
-					"phone", user.Phone.String)
-			}
-		}()
+// IssueTokens mints an access token, starts a new refresh token family and
+// records the session. clientID and scope are empty for first-party logins.
+func (s *SSOAuthService) IssueTokens(user *models.User, clientID, scope string, device DeviceInfo) (*TokenPair, error) {
+	familyID, err := generateFamilyID()
+	if err != nil {
+		return nil, apperror.Internal(err, "error generating token family")
+	}
+
+	accessToken, err := s.JWTService.GenerateClientToken(user.ID, user.Phone.String, clientID, scope, familyID)
+	if err != nil {
+		return nil, apperror.Internal(err, "error generating JWT token")
+	}
+
+	refreshToken, err := generateOpaqueToken()
+	if err != nil {
+		return nil, apperror.Internal(err, "error generating refresh token")
 	}
 
-	token, err := s.JWTService.GenerateToken(user.ID, normalizedPhone)
+	expireAt := time.Now().Add(s.RefreshTTL)
+	err = s.TokenRepo.CreateRefreshToken(user.ID, hashToken(refreshToken), familyID, clientID, scope, device.Agent, device.IP, expireAt)
 	if err != nil {
-		return "", fmt.Errorf("error generating JWT token: %w", err)
+		return nil, apperror.Internal(err, "failed to save token")
+	}
+
+	err = s.Sessions.Start(user.ID, familyID, clientID, accessToken, device, expireAt)
+	if err != nil {
+		return nil, apperror.Internal(err, "failed to save token")
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(s.JWTService.AccessTTL().Seconds()),
+		Scope:        scope,
+	}, nil
+}
+
+// Refresh exchanges a refresh token for a new token pair. Every refresh token is
+// single-use: presenting one that was already rotated is treated as theft and
+// revokes the whole family, logging out both the attacker and the victim.
+func (s *SSOAuthService) Refresh(ctx context.Context, refreshToken, clientID, agent, ip string) (*TokenPair, error) {
+	if refreshToken == "" {
+		return nil, apperror.ErrInvalidRefreshToken
 	}
 
-	return token, nil
+	current, err := s.TokenRepo.FindRefreshToken(hashToken(refreshToken))
+	if err != nil {
+		return nil, apperror.Internal(err, "error finding refresh token")
+	}
+	if current == nil || current.RevokedAt.Valid || current.ClientID.String != clientID {
+		return nil, apperror.ErrInvalidRefreshToken
+	}
+	if current.RotatedAt.Valid {
+		s.revokeFamily(ctx, current, "reuse")
+		return nil, apperror.ErrRefreshTokenReused
+	}
+	if time.Now().After(current.ExpireAt) {
+		return nil, apperror.ErrRefreshTokenExpired
+	}
+
+	user, err := s.UserRepo.FindByID(current.UserID)
+	if err != nil {
+		return nil, apperror.Internal(err, "error finding user in repository")
+	}
+	if user == nil {
+		s.revokeFamily(ctx, current, "user not found")
+		return nil, apperror.ErrInvalidRefreshToken
+	}
+
+	nextToken, err := generateOpaqueToken()
+	if err != nil {
+		return nil, apperror.Internal(err, "error generating refresh token")
+	}
+
+	expireAt := time.Now().Add(s.RefreshTTL)
+	rotated, err := s.TokenRepo.RotateRefreshToken(current, hashToken(nextToken), agent, ip, expireAt)
+	if err != nil {
+		return nil, apperror.Internal(err, "failed to save token")
+	}
+	if !rotated {
+		s.revokeFamily(ctx, current, "concurrent reuse")
+		return nil, apperror.ErrRefreshTokenReused
+	}
+
+	accessToken, err := s.JWTService.GenerateClientToken(user.ID, user.Phone.String, clientID, current.Scope.String, current.FamilyID)
+	if err != nil {
+		return nil, apperror.Internal(err, "error generating JWT token")
+	}
+
+	if err := s.Sessions.Refresh(current.FamilyID, accessToken, agent, ip, expireAt); err != nil {
+		s.Logger.WarnContext(ctx, "Failed to update session", "family_id", current.FamilyID, "error", err)
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: nextToken,
+		ExpiresIn:    int64(s.JWTService.AccessTTL().Seconds()),
+		Scope:        current.Scope.String,
+	}, nil
+}
+
+func (s *SSOAuthService) revokeFamily(ctx context.Context, token *models.UserRefreshToken, reason string) {
+	s.Logger.WarnContext(ctx, "Revoking refresh token family",
+		"user_id", token.UserID,
+		"family_id", token.FamilyID,
+		"reason", reason)
+
+	if err := s.TokenRepo.RevokeRefreshFamily(token.FamilyID); err != nil {
+		s.Logger.ErrorContext(ctx, "Failed to revoke refresh token family", "family_id", token.FamilyID, "error", err)
+	}
+	if err := s.CodeCache.AddFamilyToBlacklist(ctx, token.FamilyID, s.JWTService.AccessTTL()); err != nil {
+		s.Logger.ErrorContext(ctx, "Failed to blacklist token family", "family_id", token.FamilyID, "error", err)
+	}
 }
 
-func (s *SSOAuthService) Logout(token string) error {
+func (s *SSOAuthService) Logout(ctx context.Context, token string) error {
 	_, err := s.JWTService.ValidateToken(token)
 	if err != nil {
-		return fmt.Errorf("invalid token: %w", err)
+		return apperror.ErrInvalidToken.Wrap(err)
 	}
 
-	err = s.CodeCache.AddToBlacklist(context.Background(), token, 24*time.Hour)
+	err = s.CodeCache.AddToBlacklist(ctx, token, s.JWTService.AccessTTL())
 	if err != nil {
-		return fmt.Errorf("failed to add token to blacklist: %w", err)
+		return apperror.Internal(err, "failed to add token to blacklist")
 	}
 
-	err = s.TokenRepo.Deactivate(token)
+	err = s.Sessions.End(token)
 	if err != nil {
-		s.Logger.Warn("Failed to deactivate token in database", "error", err)
+		s.Logger.WarnContext(ctx, "Failed to end session", "error", err)
 	}
 
 	return nil
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS user_refresh_tokens (
+    id          BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    user_id     BIGINT          NOT NULL,
+    token_hash  CHAR(64)        NOT NULL,
+    family_id   CHAR(32)        NOT NULL,
+    agent       VARCHAR(512)    NULL,
+    ip          VARCHAR(64)     NULL,
+    expire_at   DATETIME        NOT NULL,
+    rotated_at  DATETIME        NULL,
+    revoked_at  DATETIME        NULL,
+    created_at  DATETIME        NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_user_refresh_tokens_token_hash (token_hash),
+    KEY idx_user_refresh_tokens_family_id (family_id),
+    KEY idx_user_refresh_tokens_user_id (user_id)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
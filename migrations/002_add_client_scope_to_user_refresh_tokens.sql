@@ -0,0 +1,3 @@
+ALTER TABLE user_refresh_tokens
+    ADD COLUMN client_id VARCHAR(64)  NOT NULL DEFAULT '' AFTER family_id,
+    ADD COLUMN scope     VARCHAR(512) NOT NULL DEFAULT '' AFTER client_id;
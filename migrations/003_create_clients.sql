@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS clients (
+    id                 BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    client_id          VARCHAR(64)     NOT NULL,
+    client_secret_hash VARCHAR(255)    NOT NULL DEFAULT '',
+    name               VARCHAR(255)    NOT NULL,
+    redirect_uris      TEXT            NOT NULL,
+    grant_types        VARCHAR(255)    NOT NULL,
+    scopes             VARCHAR(512)    NOT NULL,
+    is_active          TINYINT(1)      NOT NULL DEFAULT 1,
+    created_at         DATETIME        NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at         DATETIME        NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_clients_client_id (client_id)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,8 @@
+ALTER TABLE user_access_tokens
+    ADD COLUMN family_id    CHAR(32)     NULL AFTER token,
+    ADD COLUMN client_id    VARCHAR(64)  NOT NULL DEFAULT '' AFTER family_id,
+    ADD COLUMN platform     VARCHAR(32)  NULL AFTER ip,
+    ADD COLUMN device_uuid  VARCHAR(128) NULL AFTER platform,
+    ADD COLUMN last_seen_at DATETIME     NULL AFTER login_at,
+    ADD INDEX idx_user_access_tokens_user_expire (user_id, expire_at),
+    ADD INDEX idx_user_access_tokens_family (family_id);
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS sms_messages (
+    id                  BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    phone               VARCHAR(32)     NOT NULL,
+    provider            VARCHAR(32)     NOT NULL DEFAULT '',
+    provider_message_id VARCHAR(64)     NOT NULL DEFAULT '',
+    status              VARCHAR(16)     NOT NULL,
+    provider_status     VARCHAR(64)     NOT NULL DEFAULT '',
+    parts               INT             NOT NULL DEFAULT 0,
+    cost                VARCHAR(32)     NOT NULL DEFAULT '',
+    error               VARCHAR(512)    NOT NULL DEFAULT '',
+    sent_at             DATETIME        NULL,
+    delivered_at        DATETIME        NULL,
+    checked_at          DATETIME        NULL,
+    created_at          DATETIME        NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at          DATETIME        NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (id),
+    INDEX idx_sms_messages_phone (phone, created_at),
+    INDEX idx_sms_messages_status (status, created_at)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,11 @@
+-- Phones used to be stored as 11 digits starting with 7. Rewrite them in E.164.
+-- Until this has run, lookups also match the bare digits, so it is safe to
+-- apply after the deploy. Other legacy shapes are left for manual review:
+--   SELECT id, phone FROM `user` WHERE phone IS NOT NULL AND phone NOT LIKE '+%';
+UPDATE `user`
+SET phone = CONCAT('+', phone)
+WHERE phone REGEXP '^7[0-9]{10}$';
+
+UPDATE test_accounts
+SET phone = CONCAT('+', phone)
+WHERE phone REGEXP '^7[0-9]{10}$';
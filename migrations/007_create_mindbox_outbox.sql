@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS mindbox_outbox (
+    id              BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    user_id         BIGINT          NOT NULL,
+    operation       VARCHAR(32)     NOT NULL,
+    payload         TEXT            NOT NULL,
+    status          VARCHAR(16)     NOT NULL,
+    attempts        INT             NOT NULL DEFAULT 0,
+    last_error      VARCHAR(512)    NOT NULL DEFAULT '',
+    next_attempt_at DATETIME        NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    sent_at         DATETIME        NULL,
+    created_at      DATETIME        NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at      DATETIME        NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (id),
+    INDEX idx_mindbox_outbox_due (status, next_attempt_at),
+    INDEX idx_mindbox_outbox_user (user_id)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,5 @@
+-- mind_box_user_id is filled from Mindbox responses from now on; these record
+-- how Mindbox processed the customer the last time and when that was.
+ALTER TABLE user_mind_box
+    ADD COLUMN mind_box_status    VARCHAR(32) NULL,
+    ADD COLUMN mind_box_synced_at DATETIME    NULL;
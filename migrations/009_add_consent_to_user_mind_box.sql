@@ -0,0 +1,27 @@
+-- Consent to be contacted is kept per point of contact. NULL means the user
+-- never said; only stated consent is sent to Mindbox. consent_to_mailings
+-- stays set while any of them is granted.
+ALTER TABLE user_mind_box
+    ADD COLUMN consent_email   TINYINT(1) NULL,
+    ADD COLUMN consent_sms     TINYINT(1) NULL,
+    ADD COLUMN consent_webpush TINYINT(1) NULL;
+
+-- consent_to_mailings defaulted to false, so only a true value was stated.
+UPDATE user_mind_box
+SET consent_email = 1, consent_sms = 1, consent_webpush = 1
+WHERE consent_to_mailings = 1;
+
+-- Every change of consent, with where it came from.
+CREATE TABLE IF NOT EXISTS user_consent_log (
+    id         BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    user_id    BIGINT          NOT NULL,
+    channel    VARCHAR(16)     NOT NULL,
+    granted    TINYINT(1)      NOT NULL,
+    source     VARCHAR(16)     NOT NULL,
+    platform   VARCHAR(16)     NOT NULL DEFAULT '',
+    ip         VARCHAR(64)     NOT NULL DEFAULT '',
+    agent      VARCHAR(255)    NOT NULL DEFAULT '',
+    created_at DATETIME        NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id),
+    INDEX idx_user_consent_log_user (user_id, created_at)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
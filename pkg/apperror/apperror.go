@@ -0,0 +1,96 @@
+// Package apperror defines errors that carry a stable, machine-readable code
+// and the HTTP status they map to. Clients branch on Code; Message is safe to
+// show to users and Err keeps the underlying cause for logs.
+package apperror
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type Code string
+
+type Error struct {
+	Code    Code
+	Status  int
+	Message string
+	Data    any
+	Err     error
+}
+
+func New(code Code, status int, message string) *Error {
+	return &Error{Code: code, Status: status, Message: message}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches errors by code, so errors.Is(err, ErrInvalidPhone) holds for any
+// copy made with WithMessage, WithData or Wrap.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+func (e *Error) WithMessage(message string) *Error {
+	c := *e
+	c.Message = message
+	return &c
+}
+
+func (e *Error) WithData(data any) *Error {
+	c := *e
+	c.Data = data
+	return &c
+}
+
+func (e *Error) Wrap(err error) *Error {
+	c := *e
+	c.Err = err
+	return &c
+}
+
+// RetryAfter is the Data of errors the client may retry after a delay.
+type RetryAfter struct {
+	RetryAfter int64 `json:"retry_after"`
+}
+
+// WithRetryAfter attaches the delay in whole seconds, rounded up.
+func (e *Error) WithRetryAfter(d time.Duration) *Error {
+	return e.WithData(RetryAfter{RetryAfter: int64((d + time.Second - 1) / time.Second)})
+}
+
+// Internal hides err behind a generic message; context ends up in logs only.
+func Internal(err error, context string) *Error {
+	return ErrInternal.Wrap(fmt.Errorf("%s: %w", context, err))
+}
+
+// Database is Internal for storage failures.
+func Database(err error, context string) *Error {
+	return ErrDatabase.Wrap(fmt.Errorf("%s: %w", context, err))
+}
+
+// From returns err as an *Error, treating anything untyped as internal.
+func From(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return ErrInternal.Wrap(err)
+}
+
+// IsServerError reports whether err is the service's fault rather than the
+// caller's.
+func IsServerError(err error) bool {
+	return From(err).Status >= http.StatusInternalServerError
+}
@@ -0,0 +1,62 @@
+package apperror
+
+import "net/http"
+
+// Codes are part of the public API contract: never rename or reuse one.
+const (
+	CodeInvalidRequest       Code = "invalid_request"
+	CodeInvalidPhone         Code = "invalid_phone"
+	CodeInvalidCode          Code = "invalid_code"
+	CodeCodeExpired          Code = "code_expired"
+	CodeVerificationMismatch Code = "verification_mismatch"
+	CodeTooManyAttempts      Code = "too_many_attempts"
+	CodeResendCooldown       Code = "resend_cooldown"
+	CodeSMSQuotaExceeded     Code = "sms_quota_exceeded"
+	CodeSMSBlocked           Code = "sms_blocked"
+	CodeCaptchaRequired      Code = "captcha_required"
+	CodeInvalidCaptcha       Code = "invalid_captcha"
+	CodeInvalidFraudRule     Code = "invalid_fraud_rule"
+	CodeUnsupportedChannel   Code = "unsupported_channel"
+	CodeDeliveryFailed       Code = "delivery_failed"
+	CodeUnauthorized         Code = "unauthorized"
+	CodeInvalidToken         Code = "invalid_token"
+	CodeForbidden            Code = "forbidden"
+	CodeInvalidRefreshToken  Code = "invalid_refresh_token"
+	CodeRefreshTokenExpired  Code = "refresh_token_expired"
+	CodeRefreshTokenReused   Code = "refresh_token_reused"
+	CodeSessionNotFound      Code = "session_not_found"
+	CodeClientNotFound       Code = "client_not_found"
+	CodeInvalidClient        Code = "invalid_client"
+	CodeNotFound             Code = "not_found"
+	CodeDatabase             Code = "database_error"
+	CodeInternal             Code = "internal_error"
+)
+
+var (
+	ErrInvalidRequest       = New(CodeInvalidRequest, http.StatusBadRequest, "Invalid request format")
+	ErrInvalidPhone         = New(CodeInvalidPhone, http.StatusBadRequest, "invalid phone number")
+	ErrInvalidCode          = New(CodeInvalidCode, http.StatusUnauthorized, "invalid verification code")
+	ErrCodeExpired          = New(CodeCodeExpired, http.StatusUnauthorized, "verification code expired or not found")
+	ErrVerificationMismatch = New(CodeVerificationMismatch, http.StatusForbidden, "the code was requested from another device, request a new one")
+	ErrTooManyAttempts      = New(CodeTooManyAttempts, http.StatusTooManyRequests, "too many attempts")
+	ErrResendCooldown       = New(CodeResendCooldown, http.StatusTooManyRequests, "a code was sent recently, wait before requesting another one")
+	ErrSMSQuotaExceeded     = New(CodeSMSQuotaExceeded, http.StatusTooManyRequests, "too many codes requested, try again later")
+	ErrSMSBlocked           = New(CodeSMSBlocked, http.StatusForbidden, "verification codes cannot be sent to this number")
+	ErrCaptchaRequired      = New(CodeCaptchaRequired, http.StatusForbidden, "captcha is required")
+	ErrInvalidCaptcha       = New(CodeInvalidCaptcha, http.StatusForbidden, "captcha is invalid")
+	ErrInvalidFraudRule     = New(CodeInvalidFraudRule, http.StatusBadRequest, "invalid fraud rule")
+	ErrUnsupportedChannel   = New(CodeUnsupportedChannel, http.StatusBadRequest, "unsupported verification channel")
+	ErrDeliveryFailed       = New(CodeDeliveryFailed, http.StatusBadGateway, "the code could not be delivered, try another channel")
+	ErrUnauthorized         = New(CodeUnauthorized, http.StatusUnauthorized, "Authorization header is required")
+	ErrInvalidToken         = New(CodeInvalidToken, http.StatusUnauthorized, "invalid token")
+	ErrForbidden            = New(CodeForbidden, http.StatusForbidden, "forbidden")
+	ErrInvalidRefreshToken  = New(CodeInvalidRefreshToken, http.StatusUnauthorized, "invalid refresh token")
+	ErrRefreshTokenExpired  = New(CodeRefreshTokenExpired, http.StatusUnauthorized, "refresh token expired")
+	ErrRefreshTokenReused   = New(CodeRefreshTokenReused, http.StatusUnauthorized, "refresh token reuse detected")
+	ErrSessionNotFound      = New(CodeSessionNotFound, http.StatusNotFound, "session not found")
+	ErrClientNotFound       = New(CodeClientNotFound, http.StatusNotFound, "client not found")
+	ErrInvalidClient        = New(CodeInvalidClient, http.StatusBadRequest, "invalid client")
+	ErrNotFound             = New(CodeNotFound, http.StatusNotFound, "not found")
+	ErrDatabase             = New(CodeDatabase, http.StatusInternalServerError, "Internal server error")
+	ErrInternal             = New(CodeInternal, http.StatusInternalServerError, "Internal server error")
+)
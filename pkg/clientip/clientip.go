@@ -0,0 +1,72 @@
+// Package clientip finds the address of the client behind the reverse proxies
+// a request came through.
+package clientip
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// Resolve returns the address r came from. When the peer is one of trusted,
+// X-Forwarded-For is walked from the right, past the hops trusted proxies
+// appended, to the first one they did not: everything left of it was sent by
+// the client and may be forged.
+func Resolve(r *http.Request, trusted []netip.Prefix) string {
+	peer := host(r.RemoteAddr)
+	addr, err := netip.ParseAddr(peer)
+	if err != nil || !isTrusted(addr, trusted) {
+		return peer
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(host(strings.TrimSpace(hops[i])))
+		if err != nil {
+			// Trusted proxies only append peer addresses, so this came from
+			// the client; the hop to its right is the best known.
+			break
+		}
+		addr = hop.Unmap().WithZone("")
+		if !isTrusted(addr, trusted) {
+			break
+		}
+	}
+	return addr.String()
+}
+
+func isTrusted(addr netip.Addr, trusted []netip.Prefix) bool {
+	addr = addr.Unmap()
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// host strips the port, if any, from addr.
+func host(addr string) string {
+	if h, _, err := net.SplitHostPort(addr); err == nil {
+		return h
+	}
+	return strings.Trim(addr, "[]")
+}
+
+type ipKey struct{}
+
+// WithIP returns ctx carrying the resolved address of the client.
+func WithIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, ipKey{}, ip)
+}
+
+// FromContext returns the address stored by WithIP, or "".
+func FromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(ipKey{}).(string)
+	return ip
+}
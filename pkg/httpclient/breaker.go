@@ -0,0 +1,152 @@
+package httpclient
+
+import (
+	"sync"
+	"time"
+)
+
+type State int
+
+const (
+	StateClosed State = iota
+	StateOpen
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// breaker opens after threshold consecutive failures and rejects calls for
+// cooldown. Then it lets a single probe through: success closes it again,
+// failure reopens it for another cooldown.
+//
+// Every state change starts a new generation. Calls report back with the
+// generation they were allowed in, and results of calls that started before
+// the last change are ignored, so a slow call from before the breaker opened
+// is never taken for the probe.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+	onChange  func(from, to State)
+
+	mu         sync.Mutex
+	state      State
+	generation uint64
+	failures   int
+	openedAt   time.Time
+	probing    bool
+}
+
+// transition is a state change to report once the lock is released.
+type transition struct {
+	from, to State
+}
+
+func newBreaker(threshold int, cooldown time.Duration, onChange func(from, to State)) *breaker {
+	return &breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		onChange:  onChange,
+	}
+}
+
+// allow reports whether a call may go ahead and the generation it goes ahead
+// in. Every allowed call must be followed by done with that generation.
+func (b *breaker) allow() (uint64, bool) {
+	b.mu.Lock()
+	generation, ok, change := b.allowLocked()
+	b.mu.Unlock()
+
+	b.notify(change)
+	return generation, ok
+}
+
+func (b *breaker) allowLocked() (uint64, bool, *transition) {
+	switch b.state {
+	case StateOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return 0, false, nil
+		}
+		change := b.setState(StateHalfOpen)
+		b.probing = true
+		return b.generation, true, change
+	case StateHalfOpen:
+		if b.probing {
+			return 0, false, nil
+		}
+		b.probing = true
+		return b.generation, true, nil
+	}
+	return b.generation, true, nil
+}
+
+func (b *breaker) done(generation uint64, success bool) {
+	b.mu.Lock()
+	change := b.doneLocked(generation, success)
+	b.mu.Unlock()
+
+	b.notify(change)
+}
+
+func (b *breaker) doneLocked(generation uint64, success bool) *transition {
+	if generation != b.generation {
+		return nil
+	}
+
+	if b.state == StateHalfOpen {
+		b.probing = false
+		if success {
+			b.failures = 0
+			return b.setState(StateClosed)
+		}
+		return b.open()
+	}
+
+	if success {
+		b.failures = 0
+		return nil
+	}
+	b.failures++
+	if b.state == StateClosed && b.failures >= b.threshold {
+		return b.open()
+	}
+	return nil
+}
+
+func (b *breaker) open() *transition {
+	b.openedAt = time.Now()
+	return b.setState(StateOpen)
+}
+
+func (b *breaker) setState(state State) *transition {
+	if b.state == state {
+		return nil
+	}
+	change := &transition{from: b.state, to: state}
+	b.state = state
+	b.generation++
+	return change
+}
+
+// notify calls onChange outside the lock, so that it may take its time or
+// look at the breaker.
+func (b *breaker) notify(change *transition) {
+	if change != nil && b.onChange != nil {
+		b.onChange(change.from, change.to)
+	}
+}
+
+func (b *breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
@@ -0,0 +1,167 @@
+// Package httpclient is the outbound HTTP client shared by the external
+// integrations. Each integration gets its own Client with a timeout, a cap on
+// concurrent requests and a circuit breaker, so that one slow or failing
+// provider cannot tie up the service.
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrCircuitOpen is returned without calling the remote side while the
+	// breaker is open.
+	ErrCircuitOpen = errors.New("circuit breaker is open")
+	// ErrTooManyRequests is returned when MaxConcurrent requests are in flight.
+	ErrTooManyRequests = errors.New("too many concurrent requests")
+)
+
+type Options struct {
+	// Name identifies the integration in errors and logs.
+	Name    string
+	Timeout time.Duration
+	// MaxConcurrent caps requests in flight; further ones fail at once with
+	// ErrTooManyRequests. Zero means no limit.
+	MaxConcurrent int
+	// The breaker opens after BreakerFailures consecutive failed requests and
+	// stays open for BreakerCooldown. Zero failures disables it. Transport
+	// errors and 5xx and 429 responses count as failures.
+	BreakerFailures int
+	BreakerCooldown time.Duration
+
+	InsecureSkipVerify bool
+	// Logger, when set, receives breaker state changes.
+	Logger *slog.Logger
+}
+
+type Client struct {
+	name    string
+	http    *http.Client
+	slots   chan struct{}
+	breaker *breaker
+}
+
+func New(opts Options) *Client {
+	c := &Client{
+		name: opts.Name,
+		http: &http.Client{
+			Timeout: opts.Timeout,
+			Transport: &http.Transport{
+				Proxy:               http.ProxyFromEnvironment,
+				TLSClientConfig:     &tls.Config{InsecureSkipVerify: opts.InsecureSkipVerify},
+				MaxIdleConnsPerHost: max(opts.MaxConcurrent, http.DefaultMaxIdleConnsPerHost),
+				IdleConnTimeout:     90 * time.Second,
+			},
+		},
+	}
+
+	if opts.MaxConcurrent > 0 {
+		c.slots = make(chan struct{}, opts.MaxConcurrent)
+	}
+
+	if opts.BreakerFailures > 0 {
+		c.breaker = newBreaker(opts.BreakerFailures, opts.BreakerCooldown, func(from, to State) {
+			if opts.Logger == nil {
+				return
+			}
+			level := slog.LevelInfo
+			if to == StateOpen {
+				level = slog.LevelWarn
+			}
+			opts.Logger.Log(context.Background(), level, "Circuit breaker state changed",
+				"integration", opts.Name,
+				"from", from.String(),
+				"to", to.String())
+		})
+	}
+
+	return c
+}
+
+// Do sends req. The caller must close the response body, which also frees the
+// request's concurrency slot.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	if c.slots != nil {
+		select {
+		case c.slots <- struct{}{}:
+		default:
+			return nil, fmt.Errorf("%s: %w", c.name, ErrTooManyRequests)
+		}
+	}
+
+	var generation uint64
+	if c.breaker != nil {
+		var ok bool
+		if generation, ok = c.breaker.allow(); !ok {
+			c.release()
+			return nil, fmt.Errorf("%s: %w", c.name, ErrCircuitOpen)
+		}
+	}
+
+	resp, err := c.http.Do(req)
+	if c.breaker != nil {
+		c.breaker.done(generation, err == nil && resp.StatusCode < http.StatusInternalServerError && resp.StatusCode != http.StatusTooManyRequests)
+	}
+	if err != nil {
+		c.release()
+		return nil, err
+	}
+
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: c.release}
+	return resp, nil
+}
+
+func (c *Client) Get(rawURL string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+func (c *Client) PostForm(rawURL string, data url.Values) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, rawURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c.Do(req)
+}
+
+// State returns the breaker state; a client without a breaker is always closed.
+func (c *Client) State() State {
+	if c.breaker == nil {
+		return StateClosed
+	}
+	return c.breaker.State()
+}
+
+func (c *Client) release() {
+	if c.slots != nil {
+		<-c.slots
+	}
+}
+
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+	closed  bool
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	if !b.closed {
+		b.closed = true
+		b.release()
+	}
+	return err
+}
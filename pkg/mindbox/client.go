@@ -0,0 +1,176 @@
+// Package mindbox is a client for the Mindbox operations API (v3).
+//
+// Each platform the service is used from has its own Mindbox endpoint, so a
+// request names the platform and the client picks the endpoint id and secret
+// key for it. Failures are returned as *Error and classified with
+// ErrValidation, ErrAuth and ErrTransient.
+package mindbox
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Doer sends HTTP requests; *httpclient.Client and *http.Client both are one.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Endpoint is the Mindbox endpoint of one platform. Auth is the full
+// Authorization header value.
+type Endpoint struct {
+	ID   string
+	Auth string
+}
+
+type Config struct {
+	// URL is the operations URL, e.g. https://api.mindbox.ru/v3/operations/sync.
+	URL string
+	// OperationPrefix is prepended to every operation name with a dot.
+	OperationPrefix string
+	// Endpoints are keyed by platform.
+	Endpoints map[string]Endpoint
+}
+
+// Device is the client a request is made on behalf of.
+type Device struct {
+	Platform  string
+	UUID      string
+	UserAgent string
+}
+
+type Client struct {
+	cfg  Config
+	http Doer
+}
+
+func New(cfg Config, doer Doer) *Client {
+	return &Client{
+		cfg:  cfg,
+		http: doer,
+	}
+}
+
+func (c *Client) RegisterCustomer(device Device, customer Customer) (*Response, error) {
+	return c.customerOperation("RegisterCustomer", device, customer)
+}
+
+func (c *Client) AuthorizeCustomer(device Device, customer Customer) (*Response, error) {
+	return c.customerOperation("AuthorizeCustomer", device, customer)
+}
+
+func (c *Client) EditCustomer(device Device, customer Customer) (*Response, error) {
+	return c.customerOperation("EditCustomer", device, customer)
+}
+
+func (c *Client) customerOperation(operation string, device Device, customer Customer) (*Response, error) {
+	return c.Call(operation, device, CustomerRequest{
+		Customer:             customer,
+		ExecutionDateTimeUtc: time.Now().UTC().Format("2006-01-02 15:04:05.000"),
+	})
+}
+
+// Call sends body as operation, without the prefix, and returns the response.
+// Anything but a Success response is an *Error.
+func (c *Client) Call(operation string, device Device, body any) (*Response, error) {
+	endpoint, ok := c.cfg.Endpoints[device.Platform]
+	if !ok {
+		return nil, &Error{
+			Kind:      ErrValidation,
+			Operation: operation,
+			Message:   fmt.Sprintf("no endpoint for platform %q", device.Platform),
+		}
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, &Error{Kind: ErrValidation, Operation: operation, Err: err}
+	}
+
+	query := url.Values{}
+	query.Set("endpointId", endpoint.ID)
+	query.Set("operation", c.OperationName(operation))
+	if device.UUID != "" {
+		query.Set("deviceUUID", device.UUID)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.cfg.URL+"?"+query.Encode(), bytes.NewReader(data))
+	if err != nil {
+		return nil, &Error{Kind: ErrValidation, Operation: operation, Err: err}
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", endpoint.Auth)
+	if device.UserAgent != "" {
+		req.Header.Set("User-Agent", device.UserAgent)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, &Error{Kind: ErrTransient, Operation: operation, Err: err}
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &Error{Kind: ErrTransient, Operation: operation, HTTPStatus: resp.StatusCode, Err: err}
+	}
+
+	// Error responses usually carry a body of the same shape; it is only used
+	// to describe the error when it does.
+	var result Response
+	parseErr := json.Unmarshal(raw, &result)
+
+	if resp.StatusCode != http.StatusOK {
+		e := responseError(operation, resp.StatusCode, &result)
+		e.Kind = kindOfHTTPStatus(resp.StatusCode)
+		if parseErr != nil {
+			e.Message = truncate(strings.TrimSpace(string(raw)), 512)
+		}
+		return nil, e
+	}
+	if parseErr != nil {
+		return nil, &Error{Kind: ErrTransient, Operation: operation, HTTPStatus: resp.StatusCode, Err: parseErr}
+	}
+	if result.Status != StatusSuccess {
+		e := responseError(operation, resp.StatusCode, &result)
+		e.Kind = kindOfStatus(result.Status)
+		return nil, e
+	}
+
+	return &result, nil
+}
+
+// OperationName returns operation with the configured prefix, as Mindbox
+// knows it.
+func (c *Client) OperationName(operation string) string {
+	if c.cfg.OperationPrefix == "" {
+		return operation
+	}
+	return c.cfg.OperationPrefix + "." + operation
+}
+
+func responseError(operation string, httpStatus int, resp *Response) *Error {
+	return &Error{
+		Operation:          operation,
+		HTTPStatus:         httpStatus,
+		Status:             resp.Status,
+		Message:            resp.ErrorMessage,
+		ValidationMessages: resp.ValidationMessages,
+		ErrorID:            resp.ErrorID,
+	}
+}
+
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max])
+}
@@ -0,0 +1,88 @@
+package mindbox
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Every error returned by Client matches one of these with errors.Is.
+var (
+	// ErrValidation means Mindbox rejected the request itself; sending it
+	// again will not help.
+	ErrValidation = errors.New("validation error")
+	// ErrAuth means the endpoint id or the secret key was not accepted.
+	ErrAuth = errors.New("authorization error")
+	// ErrTransient means the request may succeed later: the network, the
+	// circuit breaker or Mindbox itself failed.
+	ErrTransient = errors.New("transient error")
+)
+
+// Error is a failed operation.
+type Error struct {
+	// Kind is ErrValidation, ErrAuth or ErrTransient.
+	Kind      error
+	Operation string
+	// HTTPStatus is zero when no response was received.
+	HTTPStatus         int
+	Status             string
+	Message            string
+	ValidationMessages []ValidationMessage
+	ErrorID            string
+	// Err is the transport error, if that is what failed.
+	Err error
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "mindbox %s: %v", e.Operation, e.Kind)
+	if e.HTTPStatus != 0 {
+		fmt.Fprintf(&b, ": http %d", e.HTTPStatus)
+	}
+	if e.Status != "" {
+		fmt.Fprintf(&b, ": %s", e.Status)
+	}
+	for _, m := range e.ValidationMessages {
+		fmt.Fprintf(&b, ": %s: %s", m.Location, m.Message)
+	}
+	if e.Message != "" {
+		fmt.Fprintf(&b, ": %s", e.Message)
+	}
+	if e.ErrorID != "" {
+		fmt.Fprintf(&b, " (error id %s)", e.ErrorID)
+	}
+	if e.Err != nil {
+		fmt.Fprintf(&b, ": %v", e.Err)
+	}
+	return b.String()
+}
+
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// kindOfStatus classifies a response by its Status. An unknown status is
+// treated as transient, like a response that could not be read.
+func kindOfStatus(status string) error {
+	switch status {
+	case StatusValidationError, StatusProtocolError:
+		return ErrValidation
+	}
+	return ErrTransient
+}
+
+// kindOfHTTPStatus classifies a response that was not 200 OK.
+func kindOfHTTPStatus(code int) error {
+	switch {
+	case code == http.StatusUnauthorized || code == http.StatusForbidden:
+		return ErrAuth
+	case code == http.StatusRequestTimeout || code == http.StatusTooManyRequests || code >= http.StatusInternalServerError:
+		return ErrTransient
+	}
+	return ErrValidation
+}
@@ -0,0 +1,62 @@
+package mindbox
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestKindOfHTTPStatus(t *testing.T) {
+	tests := []struct {
+		code int
+		want error
+	}{
+		{http.StatusBadRequest, ErrValidation},
+		{http.StatusNotFound, ErrValidation},
+		{http.StatusUnauthorized, ErrAuth},
+		{http.StatusForbidden, ErrAuth},
+		{http.StatusRequestTimeout, ErrTransient},
+		{http.StatusTooManyRequests, ErrTransient},
+		{http.StatusInternalServerError, ErrTransient},
+		{http.StatusBadGateway, ErrTransient},
+		{http.StatusServiceUnavailable, ErrTransient},
+	}
+	for _, tt := range tests {
+		if got := kindOfHTTPStatus(tt.code); got != tt.want {
+			t.Errorf("kindOfHTTPStatus(%d) = %v, want %v", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestKindOfStatus(t *testing.T) {
+	tests := []struct {
+		status string
+		want   error
+	}{
+		{StatusValidationError, ErrValidation},
+		{StatusProtocolError, ErrValidation},
+		{StatusInternalServerError, ErrTransient},
+		{"SomethingNew", ErrTransient},
+	}
+	for _, tt := range tests {
+		if got := kindOfStatus(tt.status); got != tt.want {
+			t.Errorf("kindOfStatus(%q) = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestErrorIs(t *testing.T) {
+	cause := errors.New("connection reset")
+	err := fmt.Errorf("send: %w", &Error{Kind: ErrTransient, Operation: "EditCustomer", Err: cause})
+
+	if !errors.Is(err, ErrTransient) {
+		t.Error("error does not match its kind")
+	}
+	if errors.Is(err, ErrValidation) || errors.Is(err, ErrAuth) {
+		t.Error("error matches another kind")
+	}
+	if !errors.Is(err, cause) {
+		t.Error("error does not unwrap to its cause")
+	}
+}
@@ -0,0 +1,299 @@
+// Package mindboxtest runs a fake Mindbox operations API on a local port, so
+// code that talks to Mindbox can be exercised without the network.
+//
+// The fake keeps customers in memory and answers the customer operations the
+// way Mindbox does in the common cases: RegisterCustomer and AuthorizeCustomer
+// match the customer by mindboxId, websiteID or mobile phone and create it when
+// there is none; EditCustomer changes a known customer. Every request is
+// recorded, and any operation can be given a canned answer with Stub.
+package mindboxtest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"sso/pkg/mindbox"
+)
+
+// Operation is a request the server received.
+type Operation struct {
+	// Name is the operation as sent, with its prefix.
+	Name          string
+	EndpointID    string
+	DeviceUUID    string
+	Authorization string
+	UserAgent     string
+	Body          []byte
+	// Customer is the customer of the body, if it had one.
+	Customer mindbox.Customer
+}
+
+type stub struct {
+	httpStatus int
+	body       any
+}
+
+type Server struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	endpoints  map[string]string
+	operations []Operation
+	customers  []*mindbox.Customer
+	nextID     int64
+	stubs      map[string][]stub
+}
+
+// NewServer starts a server. Close it when done.
+func NewServer() *Server {
+	s := &Server{
+		endpoints: make(map[string]string),
+		stubs:     make(map[string][]stub),
+		nextID:    1000,
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Config returns a client config for this server with an endpoint for each of
+// platforms. Their endpoint ids and keys are accepted from then on; requests
+// with any other endpoint get 401.
+func (s *Server) Config(operationPrefix string, platforms ...string) mindbox.Config {
+	cfg := mindbox.Config{
+		URL:             s.URL + "/v3/operations/sync",
+		OperationPrefix: operationPrefix,
+		Endpoints:       make(map[string]mindbox.Endpoint, len(platforms)),
+	}
+	for _, platform := range platforms {
+		endpoint := mindbox.Endpoint{
+			ID:   "endpoint-" + platform,
+			Auth: `Mindbox secretKey="secret-` + platform + `"`,
+		}
+		s.RequireAuth(endpoint.ID, endpoint.Auth)
+		cfg.Endpoints[platform] = endpoint
+	}
+	return cfg
+}
+
+// RequireAuth accepts requests to endpointID only with auth as their
+// Authorization header. Until it is called, every request is accepted.
+func (s *Server) RequireAuth(endpointID, auth string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.endpoints[endpointID] = auth
+}
+
+// Stub answers the next request for operation, given without its prefix,
+// with httpStatus and body marshalled as JSON instead of handling it. Stubs
+// of one operation are used in the order they were added.
+func (s *Server) Stub(operation string, httpStatus int, body any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stubs[operation] = append(s.stubs[operation], stub{httpStatus: httpStatus, body: body})
+}
+
+// Operations returns the requests received so far, oldest first.
+func (s *Server) Operations() []Operation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Operation(nil), s.operations...)
+}
+
+// Customers returns the customers the server knows, in the order they were
+// created.
+func (s *Server) Customers() []mindbox.Customer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	customers := make([]mindbox.Customer, len(s.customers))
+	for i, c := range s.customers {
+		customers[i] = *c
+	}
+	return customers
+}
+
+// Reset forgets operations, customers and unused stubs.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.operations = nil
+	s.customers = nil
+	s.stubs = make(map[string][]stub)
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	query := r.URL.Query()
+	op := Operation{
+		Name:          query.Get("operation"),
+		EndpointID:    query.Get("endpointId"),
+		DeviceUUID:    query.Get("deviceUUID"),
+		Authorization: r.Header.Get("Authorization"),
+		UserAgent:     r.UserAgent(),
+		Body:          body,
+	}
+
+	var req mindbox.CustomerRequest
+	parseErr := json.Unmarshal(body, &req)
+	op.Customer = req.Customer
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.operations = append(s.operations, op)
+
+	if auth, ok := s.endpoints[op.EndpointID]; len(s.endpoints) > 0 && (!ok || auth != op.Authorization) {
+		writeJSON(w, http.StatusUnauthorized, mindbox.Response{
+			Status:         mindbox.StatusProtocolError,
+			ErrorMessage:   "endpoint or secret key is not valid",
+			HTTPStatusCode: http.StatusUnauthorized,
+		})
+		return
+	}
+
+	name := op.Name
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	if stubs := s.stubs[name]; len(stubs) > 0 {
+		s.stubs[name] = stubs[1:]
+		writeJSON(w, stubs[0].httpStatus, stubs[0].body)
+		return
+	}
+
+	if parseErr != nil {
+		writeJSON(w, http.StatusBadRequest, mindbox.Response{
+			Status:         mindbox.StatusProtocolError,
+			ErrorMessage:   "request body is not valid JSON",
+			HTTPStatusCode: http.StatusBadRequest,
+		})
+		return
+	}
+
+	switch name {
+	case "RegisterCustomer", "AuthorizeCustomer":
+		s.upsert(w, req.Customer)
+	case "EditCustomer":
+		s.edit(w, req.Customer)
+	default:
+		writeJSON(w, http.StatusBadRequest, mindbox.Response{
+			Status:         mindbox.StatusProtocolError,
+			ErrorMessage:   "unknown operation " + op.Name,
+			HTTPStatusCode: http.StatusBadRequest,
+		})
+	}
+}
+
+func (s *Server) upsert(w http.ResponseWriter, customer mindbox.Customer) {
+	if customer.IDs.MindboxID == "" && customer.IDs.WebsiteID == "" && customer.MobilePhone == "" {
+		writeJSON(w, http.StatusOK, mindbox.Response{
+			Status: mindbox.StatusValidationError,
+			ValidationMessages: []mindbox.ValidationMessage{
+				{Message: "customer must be identified", Location: "/customer"},
+			},
+		})
+		return
+	}
+
+	if found := s.find(customer); found != nil {
+		apply(found, customer)
+		writeJSON(w, http.StatusOK, customerResponse(found, "Found"))
+		return
+	}
+
+	s.nextID++
+	created := &mindbox.Customer{
+		IDs: mindbox.CustomerIDs{
+			MindboxID: json.Number(strconv.FormatInt(s.nextID, 10)),
+			WebsiteID: customer.IDs.WebsiteID,
+		},
+	}
+	apply(created, customer)
+	s.customers = append(s.customers, created)
+	writeJSON(w, http.StatusOK, customerResponse(created, "Created"))
+}
+
+func (s *Server) edit(w http.ResponseWriter, customer mindbox.Customer) {
+	found := s.find(customer)
+	if found == nil {
+		writeJSON(w, http.StatusOK, mindbox.Response{
+			Status:   mindbox.StatusSuccess,
+			Customer: &mindbox.CustomerResult{ProcessingStatus: "NotFound"},
+		})
+		return
+	}
+	apply(found, customer)
+	writeJSON(w, http.StatusOK, customerResponse(found, "Changed"))
+}
+
+// find matches customer by mindboxId, then websiteID, then mobile phone.
+func (s *Server) find(customer mindbox.Customer) *mindbox.Customer {
+	for _, match := range []func(*mindbox.Customer) bool{
+		func(c *mindbox.Customer) bool {
+			return customer.IDs.MindboxID != "" && c.IDs.MindboxID == customer.IDs.MindboxID
+		},
+		func(c *mindbox.Customer) bool {
+			return customer.IDs.WebsiteID != "" && c.IDs.WebsiteID == customer.IDs.WebsiteID
+		},
+		func(c *mindbox.Customer) bool {
+			return customer.MobilePhone != "" && c.MobilePhone == customer.MobilePhone
+		},
+	} {
+		for _, c := range s.customers {
+			if match(c) {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+// apply copies the fields set in from to c and merges the subscriptions.
+func apply(c *mindbox.Customer, from mindbox.Customer) {
+	if from.IDs.WebsiteID != "" {
+		c.IDs.WebsiteID = from.IDs.WebsiteID
+	}
+	if from.MobilePhone != "" {
+		c.MobilePhone = from.MobilePhone
+	}
+	if from.Email != "" {
+		c.Email = from.Email
+	}
+	if from.FirstName != "" {
+		c.FirstName = from.FirstName
+	}
+	if from.LastName != "" {
+		c.LastName = from.LastName
+	}
+
+	for _, sub := range from.Subscriptions {
+		i := slices.IndexFunc(c.Subscriptions, func(existing mindbox.Subscription) bool {
+			return existing.PointOfContact == sub.PointOfContact
+		})
+		if i < 0 {
+			c.Subscriptions = append(c.Subscriptions, sub)
+		} else {
+			c.Subscriptions[i].IsSubscribed = sub.IsSubscribed
+		}
+	}
+}
+
+func customerResponse(c *mindbox.Customer, processingStatus string) mindbox.Response {
+	return mindbox.Response{
+		Status: mindbox.StatusSuccess,
+		Customer: &mindbox.CustomerResult{
+			ProcessingStatus: processingStatus,
+			IDs:              c.IDs,
+		},
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
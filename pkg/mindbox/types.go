@@ -0,0 +1,70 @@
+package mindbox
+
+import "encoding/json"
+
+// Points of contact a customer can be subscribed on.
+const (
+	PointOfContactEmail   = "Email"
+	PointOfContactSMS     = "SMS"
+	PointOfContactWebpush = "Webpush"
+)
+
+// Response statuses. Only StatusSuccess means the operation was carried out.
+const (
+	StatusSuccess             = "Success"
+	StatusValidationError     = "ValidationError"
+	StatusProtocolError       = "ProtocolError"
+	StatusInternalServerError = "InternalServerError"
+)
+
+// Customer is the customer of a request. Empty fields are left out, so
+// Mindbox keeps what it has for them.
+type Customer struct {
+	IDs           CustomerIDs    `json:"ids"`
+	MobilePhone   string         `json:"mobilePhone,omitempty"`
+	Email         string         `json:"email,omitempty"`
+	FirstName     string         `json:"firstName,omitempty"`
+	LastName      string         `json:"lastName,omitempty"`
+	Subscriptions []Subscription `json:"subscriptions,omitempty"`
+}
+
+// CustomerIDs identify a customer. MindboxID is the id Mindbox assigned; when
+// it is known Mindbox matches the customer by it.
+type CustomerIDs struct {
+	MindboxID json.Number `json:"mindboxId,omitempty"`
+	WebsiteID string      `json:"websiteID,omitempty"`
+}
+
+type Subscription struct {
+	PointOfContact string `json:"pointOfContact"`
+	IsSubscribed   bool   `json:"isSubscribed"`
+}
+
+// CustomerRequest is the body of the customer operations.
+type CustomerRequest struct {
+	Customer             Customer `json:"customer"`
+	ExecutionDateTimeUtc string   `json:"executionDateTimeUtc,omitempty"`
+}
+
+// Response is the body Mindbox answers an operation with. Only the fields that
+// go with Status are set.
+type Response struct {
+	Status             string              `json:"status"`
+	Customer           *CustomerResult     `json:"customer,omitempty"`
+	ValidationMessages []ValidationMessage `json:"validationMessages,omitempty"`
+	ErrorMessage       string              `json:"errorMessage,omitempty"`
+	ErrorID            string              `json:"errorId,omitempty"`
+	HTTPStatusCode     int                 `json:"httpStatusCode,omitempty"`
+}
+
+// CustomerResult tells how Mindbox matched the customer of a request, e.g.
+// Found, Created or Changed, and under which ids it keeps it.
+type CustomerResult struct {
+	ProcessingStatus string      `json:"processingStatus"`
+	IDs              CustomerIDs `json:"ids"`
+}
+
+type ValidationMessage struct {
+	Message  string `json:"message"`
+	Location string `json:"location"`
+}
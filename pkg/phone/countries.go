@@ -0,0 +1,158 @@
+package phone
+
+// Country describes how numbers of one country are dialled. Lengths are of the
+// national significant number, without calling code and trunk prefix.
+type Country struct {
+	ISO         string
+	CallingCode string
+	TrunkPrefix string
+	Lengths     []int
+
+	// LeadingDigits tells countries sharing a calling code apart.
+	LeadingDigits []string
+
+	// MobilePrefixes classify a number as mobile; every other number is fixed
+	// line. Countries without them classify every number as Unknown.
+	MobilePrefixes []string
+}
+
+// countries covers the markets we serve and their neighbours. Countries that
+// share a calling code are listed most specific first.
+var countries = []Country{
+	{
+		ISO:            "KZ",
+		CallingCode:    "7",
+		TrunkPrefix:    "8",
+		Lengths:        []int{10},
+		LeadingDigits:  []string{"6", "7"},
+		MobilePrefixes: []string{"700", "701", "702", "705", "706", "707", "708", "747", "750", "751", "760", "761", "762", "763", "764", "771", "775", "776", "777", "778"},
+	},
+	{
+		ISO:            "RU",
+		CallingCode:    "7",
+		TrunkPrefix:    "8",
+		Lengths:        []int{10},
+		LeadingDigits:  []string{"3", "4", "8", "9"},
+		MobilePrefixes: []string{"9"},
+	},
+	{
+		ISO:            "UZ",
+		CallingCode:    "998",
+		Lengths:        []int{9},
+		MobilePrefixes: []string{"20", "33", "50", "55", "77", "88", "90", "91", "93", "94", "95", "97", "98", "99"},
+	},
+	{
+		ISO:            "KG",
+		CallingCode:    "996",
+		TrunkPrefix:    "0",
+		Lengths:        []int{9},
+		MobilePrefixes: []string{"20", "22", "50", "51", "54", "55", "56", "57", "70", "75", "77", "88", "99"},
+	},
+	{
+		ISO:            "TJ",
+		CallingCode:    "992",
+		Lengths:        []int{9},
+		MobilePrefixes: []string{"0", "11", "20", "50", "55", "77", "88", "90", "91", "92", "93", "98"},
+	},
+	{
+		ISO:            "TM",
+		CallingCode:    "993",
+		TrunkPrefix:    "8",
+		Lengths:        []int{8},
+		MobilePrefixes: []string{"6", "71"},
+	},
+	{
+		ISO:            "AZ",
+		CallingCode:    "994",
+		TrunkPrefix:    "0",
+		Lengths:        []int{9},
+		MobilePrefixes: []string{"10", "50", "51", "55", "60", "70", "77", "99"},
+	},
+	{
+		ISO:            "AM",
+		CallingCode:    "374",
+		TrunkPrefix:    "0",
+		Lengths:        []int{8},
+		MobilePrefixes: []string{"33", "4", "55", "77", "9"},
+	},
+	{
+		ISO:            "GE",
+		CallingCode:    "995",
+		TrunkPrefix:    "0",
+		Lengths:        []int{9},
+		MobilePrefixes: []string{"5"},
+	},
+	{
+		ISO:            "BY",
+		CallingCode:    "375",
+		TrunkPrefix:    "80",
+		Lengths:        []int{9},
+		MobilePrefixes: []string{"25", "29", "33", "44"},
+	},
+	{
+		ISO:            "UA",
+		CallingCode:    "380",
+		TrunkPrefix:    "0",
+		Lengths:        []int{9},
+		MobilePrefixes: []string{"39", "50", "63", "66", "67", "68", "73", "89", "91", "92", "93", "94", "95", "96", "97", "98", "99"},
+	},
+	{
+		ISO:            "MD",
+		CallingCode:    "373",
+		TrunkPrefix:    "0",
+		Lengths:        []int{8},
+		MobilePrefixes: []string{"6", "7"},
+	},
+	{
+		ISO:            "TR",
+		CallingCode:    "90",
+		TrunkPrefix:    "0",
+		Lengths:        []int{10},
+		MobilePrefixes: []string{"5"},
+	},
+	{
+		ISO:            "AE",
+		CallingCode:    "971",
+		TrunkPrefix:    "0",
+		Lengths:        []int{8, 9},
+		MobilePrefixes: []string{"5"},
+	},
+	{
+		ISO:            "CN",
+		CallingCode:    "86",
+		TrunkPrefix:    "0",
+		Lengths:        []int{10, 11},
+		MobilePrefixes: []string{"1"},
+	},
+	{
+		ISO:            "GB",
+		CallingCode:    "44",
+		TrunkPrefix:    "0",
+		Lengths:        []int{10},
+		MobilePrefixes: []string{"7"},
+	},
+	{
+		ISO:         "DE",
+		CallingCode: "49",
+		TrunkPrefix: "0",
+		Lengths:     []int{10, 11},
+		MobilePrefixes: []string{
+			"15", "16", "17",
+		},
+	},
+	{
+		ISO:         "US",
+		CallingCode: "1",
+		Lengths:     []int{10},
+	},
+}
+
+// CountryByISO returns the country with the ISO 3166-1 alpha-2 code iso.
+func CountryByISO(iso string) (Country, bool) {
+	for _, c := range countries {
+		if c.ISO == iso {
+			return c, true
+		}
+	}
+	return Country{}, false
+}
@@ -0,0 +1,203 @@
+// Package phone parses phone numbers into E.164.
+//
+// It knows the numbering plans of a limited set of countries (see countries.go)
+// well enough to validate length and tell mobile from fixed-line numbers; it
+// is not a general replacement for libphonenumber.
+package phone
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+type Type int
+
+const (
+	Unknown Type = iota
+	Mobile
+	FixedLine
+)
+
+var (
+	ErrInvalid            = errors.New("invalid phone number")
+	ErrUnsupportedCountry = errors.New("phone numbers of this country are not supported")
+	ErrNotMobile          = errors.New("phone number is not a mobile number")
+)
+
+// Number is a parsed phone number.
+type Number struct {
+	Country  Country
+	National string
+	Type     Type
+}
+
+// E164 formats n as "+<calling code><national number>".
+func (n Number) E164() string {
+	return "+" + n.Digits()
+}
+
+// Digits is E164 without the leading "+".
+func (n Number) Digits() string {
+	return n.Country.CallingCode + n.National
+}
+
+// Parser parses numbers for the allowed countries. Numbers written without a
+// country code are read as numbers of the default country.
+type Parser struct {
+	allowed       []string
+	defaultRegion string
+	requireMobile bool
+}
+
+// NewParser returns a parser for the ISO codes in allowed. An empty allowed
+// list accepts every known country.
+func NewParser(allowed []string, defaultRegion string, requireMobile bool) (*Parser, error) {
+	p := &Parser{
+		defaultRegion: strings.ToUpper(strings.TrimSpace(defaultRegion)),
+		requireMobile: requireMobile,
+	}
+	for _, iso := range allowed {
+		iso = strings.ToUpper(strings.TrimSpace(iso))
+		if iso == "" {
+			continue
+		}
+		if _, ok := CountryByISO(iso); !ok {
+			return nil, fmt.Errorf("unknown country %q", iso)
+		}
+		p.allowed = append(p.allowed, iso)
+	}
+	if p.defaultRegion != "" {
+		if _, ok := CountryByISO(p.defaultRegion); !ok {
+			return nil, fmt.Errorf("unknown default country %q", p.defaultRegion)
+		}
+	}
+	return p, nil
+}
+
+// Parse accepts international ("+998 90 123-45-67", "00998901234567"),
+// national ("8 (701) 123-45-67" for the default country) and bare
+// international digits ("77011234567").
+func (p *Parser) Parse(input string) (Number, error) {
+	input = strings.TrimSpace(input)
+	international := strings.HasPrefix(input, "+")
+
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, input)
+	if !international && strings.HasPrefix(digits, "00") {
+		international = true
+		digits = digits[2:]
+	}
+	if digits == "" || len(digits) > 15 {
+		return Number{}, ErrInvalid
+	}
+
+	var (
+		number Number
+		err    = ErrInvalid
+	)
+	if !international && p.defaultRegion != "" {
+		number, err = parseNational(p.defaultRegion, digits)
+	}
+	if err != nil {
+		number, err = parseInternational(digits)
+	}
+	if err != nil {
+		return Number{}, err
+	}
+
+	if len(p.allowed) > 0 && !slices.Contains(p.allowed, number.Country.ISO) {
+		return Number{}, ErrUnsupportedCountry
+	}
+	if p.requireMobile && number.Type == FixedLine {
+		return Number{}, ErrNotMobile
+	}
+	return number, nil
+}
+
+func parseNational(iso, digits string) (Number, error) {
+	country, _ := CountryByISO(iso)
+	return newNumber(country.CallingCode, digits)
+}
+
+func parseInternational(digits string) (Number, error) {
+	for n := 1; n <= 3 && n < len(digits); n++ {
+		number, err := newNumber(digits[:n], digits[n:])
+		if err == nil {
+			return number, nil
+		}
+		if !errors.Is(err, errUnknownCode) {
+			return Number{}, err
+		}
+	}
+	return Number{}, ErrInvalid
+}
+
+var errUnknownCode = errors.New("unknown calling code")
+
+// newNumber picks the country for a calling code and national number and
+// validates the number against it. A trunk prefix in front of the national
+// number, as in "+996 0555 123456", is dropped.
+func newNumber(callingCode, national string) (Number, error) {
+	known := false
+	for _, country := range countries {
+		if country.CallingCode != callingCode {
+			continue
+		}
+		known = true
+		national := stripTrunkPrefix(country, national)
+		if len(country.LeadingDigits) > 0 && !hasAnyPrefix(national, country.LeadingDigits) {
+			continue
+		}
+		if !validLength(country, national) {
+			return Number{}, ErrInvalid
+		}
+		return Number{
+			Country:  country,
+			National: national,
+			Type:     numberType(country, national),
+		}, nil
+	}
+	if known {
+		return Number{}, ErrInvalid
+	}
+	return Number{}, errUnknownCode
+}
+
+func stripTrunkPrefix(country Country, national string) string {
+	if country.TrunkPrefix == "" || validLength(country, national) {
+		return national
+	}
+	if trimmed, ok := strings.CutPrefix(national, country.TrunkPrefix); ok && validLength(country, trimmed) {
+		return trimmed
+	}
+	return national
+}
+
+func validLength(country Country, national string) bool {
+	return slices.Contains(country.Lengths, len(national))
+}
+
+func numberType(country Country, national string) Type {
+	if len(country.MobilePrefixes) == 0 {
+		return Unknown
+	}
+	if hasAnyPrefix(national, country.MobilePrefixes) {
+		return Mobile
+	}
+	return FixedLine
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -3,12 +3,16 @@ package response
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
+
+	"sso/pkg/apperror"
 )
 
 type Response struct {
-	Success bool        `json:"success"`
-	Message string      `json:"message"`
-	Data    interface{} `json:"data"`
+	Success bool          `json:"success"`
+	Code    apperror.Code `json:"code,omitempty"`
+	Message string        `json:"message"`
+	Data    interface{}   `json:"data"`
 }
 
 func Result(w http.ResponseWriter, status int, data any) {
@@ -66,3 +70,21 @@ func Error(w http.ResponseWriter, message string, data ...any) {
 	w.WriteHeader(http.StatusBadRequest)
 	json.NewEncoder(w).Encode(response)
 }
+
+// AppError writes err with the status and code of its apperror.Error. Errors
+// without a code are reported as internal errors.
+func AppError(w http.ResponseWriter, err error) {
+	appErr := apperror.From(err)
+	if retry, ok := appErr.Data.(apperror.RetryAfter); ok {
+		w.Header().Set("Retry-After", strconv.FormatInt(retry.RetryAfter, 10))
+	}
+	response := Response{
+		Success: false,
+		Code:    appErr.Code,
+		Message: appErr.Message,
+		Data:    appErr.Data,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(appErr.Status)
+	json.NewEncoder(w).Encode(response)
+}